@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+// Acknowledgement 对一次已知变化的确认，确认的是变化之后的状态，当前状态与确认的状态一致时不再发送通知，直到状态再次发生变化
+type Acknowledgement struct {
+	StateHash string    `json:"state_hash"`
+	Time      time.Time `json:"time"`
+	Message   string    `json:"message,omitempty"`
+}
+
+// stateHash 计算规范化之后的状态的哈希值，与对比时使用相同的规范化规则，对比时被忽略的差异不影响确认
+func (d *Differ) stateHash(target string) string {
+	return checksum(d.normalize(target))
+}
+
+func (d *Differ) ackFile(name string) string {
+	return filepath.Join(d.dataDir, name+".ack")
+}
+
+// Acknowledge 确认最后一次保存的状态，确认信息保存在 {name}.ack 中
+func (d *Differ) Acknowledge(name string) (Acknowledgement, error) {
+	versions, err := d.Versions(name)
+	if err != nil {
+		return Acknowledgement{}, err
+	}
+
+	if len(versions) == 0 {
+		return Acknowledgement{}, fmt.Errorf("no saved version found for %s", name)
+	}
+
+	latest := versions[len(versions)-1]
+	target, err := d.fs.ReadFile(filepath.Join(d.dataDir, latest.File))
+	if err != nil {
+		return Acknowledgement{}, fmt.Errorf("read %s failed: %w", latest.File, err)
+	}
+
+	ack := Acknowledgement{StateHash: d.stateHash(string(target)), Time: d.clock.Now(), Message: d.message}
+	data, err := json.Marshal(ack)
+	if err != nil {
+		return Acknowledgement{}, err
+	}
+
+	return ack, d.fs.WriteFile(d.ackFile(name), data)
+}
+
+// readAck 读取 {name}.ack 中的确认信息，没有确认或者内容无法解析时返回 false
+func (d *Differ) readAck(name string) (Acknowledgement, bool) {
+	data, err := d.fs.ReadFile(d.ackFile(name))
+	if err != nil {
+		return Acknowledgement{}, false
+	}
+
+	var ack Acknowledgement
+	if err := json.Unmarshal(data, &ack); err != nil || ack.StateHash == "" {
+		return Acknowledgement{}, false
+	}
+
+	return ack, true
+}
+
+// clearStaleAck 保存新版本时调用，新的状态与确认的状态不一致时说明状态再次发生了变化，此时清除确认信息
+func (d *Differ) clearStaleAck(name string, target string) {
+	ack, ok := d.readAck(name)
+	if !ok || ack.StateHash == d.stateHash(target) {
+		return
+	}
+
+	_ = d.fs.Delete(d.ackFile(name))
+}
+
+// Acknowledged 判断当前状态是否已经被确认，只读取确认信息，过期的确认由 Save 清除
+func (d Diff) Acknowledged() bool {
+	ack, ok := d.differ.readAck(d.name)
+	return ok && ack.StateHash == d.differ.stateHash(d.target)
+}
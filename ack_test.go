@@ -0,0 +1,96 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mylxsw/go-utils/file"
+)
+
+// stepClock 每次调用前进一秒，保证每个版本的文件名不同
+type stepClock struct {
+	now time.Time
+}
+
+func (c *stepClock) Now() time.Time {
+	c.now = c.now.Add(time.Second)
+	return c.now
+}
+
+func newTestDiffer(t *testing.T) *Differ {
+	dataDir, err := ioutil.TempDir("", "mongo-diff-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dataDir) })
+
+	return NewDiffer(file.LocalFS{}, dataDir, 3).WithClock(&stepClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)})
+}
+
+func TestAcknowledge(t *testing.T) {
+	differ := newTestDiffer(t)
+
+	for _, state := range []string{"A: 1\n", "A: 2\n"} {
+		if err := differ.DiffLatest("test", state).PrintAndSave(ioutil.Discard); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := differ.Acknowledge("test"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 状态没有变化，确认仍然有效
+	unchanged := differ.DiffLatest("test", "A: 2\n")
+	if unchanged.Changed() {
+		t.Fatalf("expect no change")
+	}
+	if !unchanged.Acknowledged() {
+		t.Fatalf("expect unchanged state to be acknowledged")
+	}
+	if err := unchanged.PrintAndSave(ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if !differ.fs.Exist(differ.ackFile("test")) {
+		t.Fatalf("expect ack to be kept after an unchanged run")
+	}
+
+	// 状态再次变化，确认失效，保存之后清除确认信息
+	changed := differ.DiffLatest("test", "A: 3\n")
+	if !changed.Changed() {
+		t.Fatalf("expect change")
+	}
+	if changed.Acknowledged() {
+		t.Fatalf("expect changed state not to be acknowledged")
+	}
+	if err := changed.PrintAndSave(ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if differ.fs.Exist(differ.ackFile("test")) {
+		t.Fatalf("expect stale ack to be cleared")
+	}
+}
+
+func TestAcknowledgedWithoutSave(t *testing.T) {
+	differ := newTestDiffer(t)
+
+	for _, state := range []string{"A: 1\n", "A: 2\n"} {
+		if err := differ.DiffLatest("test", state).PrintAndSave(ioutil.Discard); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := differ.Acknowledge("test"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 只读取确认信息，不会清除
+	if differ.DiffLatest("test", "A: 1\n").Acknowledged() {
+		t.Fatalf("expect reverted state not to be acknowledged")
+	}
+	if !differ.DiffLatest("test", "A: 2\n").Acknowledged() {
+		t.Fatalf("expect acknowledged state to stay acknowledged")
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// defaultAggregationLimit 聚合采集器默认最多输出的结果数量
+const defaultAggregationLimit = 100
+
+var aggregationLabelRegexp = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// AggregationCollector 一个聚合采集器的声明（-collectors-file 中的 aggregations），在集合上执行聚合管道，
+// 每个结果文档输出一行 AGG[label]，Limit 为 0 时最多输出 defaultAggregationLimit 个结果
+type AggregationCollector struct {
+	Label      string          `json:"label"`
+	Database   string          `json:"database"`
+	Collection string          `json:"collection"`
+	Pipeline   json.RawMessage `json:"pipeline"`
+	Limit      int             `json:"limit"`
+
+	pipeline bson.A
+}
+
+// AggregationLine 聚合采集器输出的一个结果文档，Doc 为字段按照名称排序后的紧凑扩展 JSON
+type AggregationLine struct {
+	Label string `json:"label"`
+	Doc   string `json:"doc"`
+}
+
+// validate 校验聚合采集器的声明并解析聚合管道
+func (a *AggregationCollector) validate() error {
+	if !aggregationLabelRegexp.MatchString(a.Label) {
+		return fmt.Errorf("invalid aggregation label %q: only letters, digits, _, . and - are allowed", a.Label)
+	}
+
+	if a.Database == "" || a.Collection == "" {
+		return fmt.Errorf("database and collection are required for aggregation %s", a.Label)
+	}
+
+	if a.Limit < 0 {
+		return fmt.Errorf("invalid limit %d for aggregation %s", a.Limit, a.Label)
+	}
+
+	if a.Limit == 0 {
+		a.Limit = defaultAggregationLimit
+	}
+
+	// 扩展 JSON 只能解析文档，因此将管道包装在文档中解析
+	var wrapper struct {
+		Pipeline bson.A `bson:"pipeline"`
+	}
+	if err := bson.UnmarshalExtJSON([]byte(`{"pipeline":`+string(a.Pipeline)+`}`), false, &wrapper); err != nil {
+		return fmt.Errorf("invalid pipeline for aggregation %s: %w", a.Label, err)
+	}
+
+	for i, stage := range wrapper.Pipeline {
+		doc, ok := stage.(bson.D)
+		if !ok {
+			return fmt.Errorf("stage #%d of aggregation %s is not a document", i+1, a.Label)
+		}
+
+		// 采集（包括 -check）不能修改任何数据
+		for _, elem := range doc {
+			if elem.Key == "$out" || elem.Key == "$merge" {
+				return fmt.Errorf("stage #%d of aggregation %s writes data (%s), which is not allowed", i+1, a.Label, elem.Key)
+			}
+		}
+	}
+
+	a.pipeline = wrapper.Pipeline
+	return nil
+}
+
+// Collector 将聚合采集器转换为采集器，结果按照内容排序，与聚合返回的顺序无关，
+// 超过 Limit 的结果被丢弃并输出一行 truncated，管道中没有 $sort 时保留的结果可能每次都不相同
+func (a AggregationCollector) Collector() Collector {
+	return Collector{
+		Name:   "agg:" + a.Label,
+		Custom: true,
+		Collect: func(ctx context.Context, mm *MongoManager, snapshot *Snapshot) error {
+			pipeline := append(append(bson.A{}, a.pipeline...), bson.D{{Key: "$limit", Value: a.Limit + 1}})
+			cursor, err := mm.conn.Database(a.Database).Collection(a.Collection).Aggregate(ctx, pipeline)
+			if err != nil {
+				return err
+			}
+
+			var docs []bson.D
+			if err := cursor.All(ctx, &docs); err != nil {
+				return err
+			}
+
+			truncated := len(docs) > a.Limit
+			if truncated {
+				docs = docs[:a.Limit]
+			}
+
+			lines := make([]string, 0, len(docs))
+			for _, doc := range docs {
+				data, err := bson.MarshalExtJSON(sortDocument(doc), false, false)
+				if err != nil {
+					return err
+				}
+
+				lines = append(lines, redactURI(string(data)))
+			}
+			sort.Strings(lines)
+
+			if truncated {
+				lines = append(lines, fmt.Sprintf("truncated, limit=%d", a.Limit))
+			}
+
+			for _, line := range lines {
+				snapshot.Aggregations = append(snapshot.Aggregations, AggregationLine{Label: a.Label, Doc: line})
+			}
+
+			return nil
+		},
+	}
+}
+
+// sortDocument 递归地将文档中的字段按照名称排序，数组保持原有顺序
+func sortDocument(value interface{}) interface{} {
+	switch v := value.(type) {
+	case bson.D:
+		doc := make(bson.D, 0, len(v))
+		for _, elem := range v {
+			doc = append(doc, bson.E{Key: elem.Key, Value: sortDocument(elem.Value)})
+		}
+
+		sort.SliceStable(doc, func(i, j int) bool { return doc[i].Key < doc[j].Key })
+		return doc
+	case bson.A:
+		arr := make(bson.A, 0, len(v))
+		for _, item := range v {
+			arr = append(arr, sortDocument(item))
+		}
+
+		return arr
+	default:
+		return value
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// archiveFormatVersion 归档文件格式的版本，格式不兼容时递增
+const archiveFormatVersion = 1
+
+// Archive 一个 diff 名称的完整历史版本，用于在不同环境之间迁移
+type Archive struct {
+	FormatVersion int              `json:"format_version"`
+	Name          string           `json:"name"`
+	ExportedAt    time.Time        `json:"exported_at"`
+	Versions      []ArchiveVersion `json:"versions"`
+}
+
+// ArchiveVersion 归档中的一个版本，Checksum 为 Content 的 sha256，导入前用于校验完整性
+type ArchiveVersion struct {
+	Timestamp   string `json:"timestamp"`
+	Content     string `json:"content"`
+	Diff        string `json:"diff,omitempty"`
+	Message     string `json:"message,omitempty"`
+	RunID       string `json:"run_id,omitempty"`
+	Checksum    string `json:"checksum"`
+	Interesting bool   `json:"interesting,omitempty"`
+}
+
+var archiveTimestampRegexp = regexp.MustCompile(`^\d{14}$`)
+
+func checksum(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// Export 将 name 的所有历史版本（包括 diff 与说明信息）导出为归档
+func (d *Differ) Export(name string) (Archive, error) {
+	versions, err := d.Versions(name)
+	if err != nil {
+		return Archive{}, err
+	}
+
+	archive := Archive{FormatVersion: archiveFormatVersion, Name: name, ExportedAt: time.Now(), Versions: make([]ArchiveVersion, 0, len(versions))}
+	for _, version := range versions {
+		targetFile := filepath.Join(d.dataDir, version.File)
+		content, err := d.fs.ReadFile(targetFile)
+		if err != nil {
+			return Archive{}, err
+		}
+
+		diffText, _ := d.fs.ReadFile(targetFile + ".diff")
+		archive.Versions = append(archive.Versions, ArchiveVersion{
+			Timestamp:   version.Timestamp,
+			Content:     string(content),
+			Diff:        string(diffText),
+			Message:     version.Message,
+			RunID:       version.RunID,
+			Checksum:    checksum(string(content)),
+			Interesting: version.Interesting,
+		})
+	}
+
+	return archive, nil
+}
+
+// Validate 校验归档的格式与每个版本的完整性
+func (a Archive) Validate() error {
+	if a.FormatVersion != archiveFormatVersion {
+		return fmt.Errorf("unsupported archive format version %d", a.FormatVersion)
+	}
+
+	if a.Name == "" || strings.ContainsAny(a.Name, `/\`) {
+		return fmt.Errorf("invalid archive name %q", a.Name)
+	}
+
+	seen := make(map[string]bool)
+	for _, version := range a.Versions {
+		if !archiveTimestampRegexp.MatchString(version.Timestamp) {
+			return fmt.Errorf("invalid version timestamp %q", version.Timestamp)
+		}
+
+		if seen[version.Timestamp] {
+			return fmt.Errorf("duplicate version %s", version.Timestamp)
+		}
+		seen[version.Timestamp] = true
+
+		if checksum(version.Content) != version.Checksum {
+			return fmt.Errorf("checksum mismatch for version %s, the archive may be corrupted", version.Timestamp)
+		}
+	}
+
+	return nil
+}
+
+// Import 将归档中的所有版本以 name 导入，保留原有的时间戳、diff 与说明信息
+//
+// 已存在的相同版本会被跳过，已存在但内容不同的版本视为冲突，此时不会导入任何版本；
+// 导入的最新版本比当前最后一次保存的版本更新时，更新最后一次保存的状态
+func (d *Differ) Import(archive Archive, name string) (int, error) {
+	if err := archive.Validate(); err != nil {
+		return 0, err
+	}
+
+	existing, err := d.Versions(name)
+	if err != nil {
+		return 0, err
+	}
+
+	existed := make(map[string]string)
+	for _, version := range existing {
+		existed[version.Timestamp] = version.File
+	}
+
+	pending := make([]ArchiveVersion, 0, len(archive.Versions))
+	for _, version := range archive.Versions {
+		existedFile, ok := existed[version.Timestamp]
+		if !ok {
+			pending = append(pending, version)
+			continue
+		}
+
+		content, err := d.fs.ReadFile(filepath.Join(d.dataDir, existedFile))
+		if err != nil {
+			return 0, err
+		}
+
+		if string(content) != version.Content {
+			return 0, fmt.Errorf("version %s already exists with different content", version.Timestamp)
+		}
+	}
+
+	latest, seq := "", 0
+	if len(existing) > 0 {
+		latest, seq = existing[len(existing)-1].Timestamp, existing[len(existing)-1].Seq
+	}
+
+	for _, version := range pending {
+		seq++
+		filename := d.versionFile(name, version.Timestamp, seq)
+		targetFile := filepath.Join(d.dataDir, filename)
+		if version.Diff != "" {
+			if err := d.fs.WriteFile(targetFile+".diff", []byte(version.Diff)); err != nil {
+				return 0, err
+			}
+		}
+		if version.Message != "" {
+			if err := d.fs.WriteFile(targetFile+".msg", []byte(version.Message)); err != nil {
+				return 0, err
+			}
+		}
+		if version.RunID != "" {
+			if err := d.fs.WriteFile(targetFile+".run", []byte(version.RunID)); err != nil {
+				return 0, err
+			}
+		}
+		if d.storeHash {
+			if err := d.fs.WriteFile(targetFile+".sha256", []byte(version.Checksum)); err != nil {
+				return 0, err
+			}
+		}
+		if version.Interesting {
+			added, removed := diffLineCounts(version.Diff)
+			if err := d.fs.WriteFile(targetFile+".interesting", []byte(strconv.Itoa(added+removed))); err != nil {
+				return 0, err
+			}
+		}
+		if err := d.fs.WriteFile(targetFile, []byte(version.Content)); err != nil {
+			return 0, err
+		}
+
+		if version.Timestamp > latest {
+			latest = version.Timestamp
+			if err := d.fs.WriteFile(filepath.Join(d.dataDir, name+".idx"), []byte(filename)); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	return len(pending), nil
+}
+
+// exportHistory 将 name 的所有历史版本以 JSON 格式写入 out
+func exportHistory(out io.Writer, differ *Differ, name string) error {
+	archive, err := differ.Export(name)
+	if err != nil {
+		return err
+	}
+
+	if len(archive.Versions) == 0 {
+		return fmt.Errorf("no saved version found for %s", name)
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", jsonIndent)
+	return encoder.Encode(archive)
+}
+
+// readArchive 从 in 中读取 -export 导出的归档并校验，归档中的名称会用于锁文件名，需要在使用前校验
+func readArchive(in io.Reader) (Archive, error) {
+	var archive Archive
+	if err := json.NewDecoder(in).Decode(&archive); err != nil {
+		return Archive{}, fmt.Errorf("invalid archive: %w", err)
+	}
+
+	return archive, archive.Validate()
+}
+
+// importHistory 将归档中的版本导入为 name 的历史版本
+func importHistory(differ *Differ, archive Archive, name string) error {
+	imported, err := differ.Import(archive, name)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("imported %d of %d versions as %s", imported, len(archive.Versions), name)
+	return nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AuthProviderSetting 认证相关的一项配置
+type AuthProviderSetting struct {
+	// Provider 认证方式，包括 internal（内置认证与授权）、ldap、kerberos
+	Provider string `json:"provider"`
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+}
+
+// authProviderSettings 从 getCmdLineOpts 中提取认证相关的配置，按照 provider、key 排序，密码等敏感信息只保留哈希值
+func authProviderSettings(opts CmdLineOpts) []AuthProviderSetting {
+	settings := make([]AuthProviderSetting, 0)
+	add := func(provider, key string, value interface{}) {
+		settings = append(settings, AuthProviderSetting{Provider: provider, Key: key, Value: formatSettingValue(key, value)})
+	}
+
+	security := opts.Parsed.Security
+	if security.Authorization != "" {
+		add("internal", "authorization", security.Authorization)
+	}
+	if security.ClusterAuthMode != "" {
+		add("internal", "clusterAuthMode", security.ClusterAuthMode)
+	}
+
+	mechanisms, _ := opts.Parsed.SetParameter["authenticationMechanisms"].(string)
+	if mechanisms != "" {
+		add("internal", "authenticationMechanisms", mechanisms)
+	}
+
+	for key, value := range flattenSettings("", security.LDAP) {
+		add("ldap", key, value)
+	}
+
+	if strings.Contains(mechanisms, "GSSAPI") {
+		add("kerberos", "enabled", true)
+	}
+	for key, value := range flattenSettings("", security.SASL) {
+		add("kerberos", key, value)
+	}
+
+	sort.Slice(settings, func(i, j int) bool {
+		if settings[i].Provider != settings[j].Provider {
+			return settings[i].Provider < settings[j].Provider
+		}
+
+		return settings[i].Key < settings[j].Key
+	})
+
+	return settings
+}
+
+// flattenSettings 将嵌套的配置展开为使用 . 连接的 key，如 bind.queryUser
+func flattenSettings(prefix string, settings bson.M) map[string]interface{} {
+	result := make(map[string]interface{})
+	for key, value := range settings {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		if nested, ok := value.(bson.M); ok {
+			for k, v := range flattenSettings(key, nested) {
+				result[k] = v
+			}
+
+			continue
+		}
+
+		result[key] = value
+	}
+
+	return result
+}
+
+// formatSettingValue 格式化配置值，密码、密钥文件等敏感配置（isSecretKey）只输出 <redacted:...> 标记，仍然可以发现配置发生了变化
+func formatSettingValue(key string, value interface{}) string {
+	var text string
+	switch val := value.(type) {
+	case bson.M:
+		// encoding/json 序列化 map 时按照 key 排序，保证输出稳定
+		data, _ := json.Marshal(val)
+		text = string(data)
+	case primitive.A:
+		items := make([]string, 0, len(val))
+		for _, item := range val {
+			items = append(items, fmt.Sprintf("%v", item))
+		}
+
+		text = strings.Join(items, ",")
+	default:
+		text = fmt.Sprintf("%v", val)
+	}
+
+	if isSecretKey(key) {
+		return redactSecret(text)
+	}
+
+	return redactURI(text)
+}
+
+// saltedHash 使用 -hash-salt 作为盐值计算哈希，只保留前 12 位，相同的输入始终得到相同的结果，只用于 -hash-users 隐藏用户名
+func saltedHash(prefix, value string) string {
+	sum := sha256.Sum256([]byte(hashSalt + value))
+	return prefix + hex.EncodeToString(sum[:])[:12]
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ShieldsBadge shields.io endpoint 徽章格式，见 https://shields.io/endpoint
+type ShieldsBadge struct {
+	SchemaVersion int    `json:"schemaVersion"`
+	Label         string `json:"label"`
+	Message       string `json:"message"`
+	Color         string `json:"color"`
+}
+
+// diffLineCounts 统计 unified diff 中新增与删除的行数，不包括文件头
+func diffLineCounts(unified string) (added, removed int) {
+	for _, line := range strings.Split(unified, "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++ "), strings.HasPrefix(line, "--- "):
+		case strings.HasPrefix(line, "+"):
+			added++
+		case strings.HasPrefix(line, "-"):
+			removed++
+		}
+	}
+
+	return added, removed
+}
+
+// badge 将 diff 渲染为只有一行的状态，没有变化时为 OK，否则为 CHANGED 加上变化的行数，
+// 指定了 -badge-json 时输出 shields.io 兼容的 JSON
+func badge(name, unified string) string {
+	added, removed := diffLineCounts(unified)
+	changes := added + removed
+
+	if !badgeJSON {
+		if changes == 0 {
+			return "OK\n"
+		}
+
+		return fmt.Sprintf("CHANGED %d\n", changes)
+	}
+
+	b := ShieldsBadge{SchemaVersion: 1, Label: name, Message: "OK", Color: "brightgreen"}
+	if changes > 0 {
+		b.Message, b.Color = fmt.Sprintf("%d changes", changes), "orange"
+	}
+
+	data, _ := json.Marshal(b)
+	return string(data) + "\n"
+}
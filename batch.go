@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// mongoURIEnv 未指定 -mongo-uri 时从该环境变量读取 MongoDB URI，批量运行时使用它向子进程传递 URI，避免凭据出现在进程列表中
+const mongoURIEnv = "MONGODIFF_MONGO_URI"
+
+// batchForbiddenArgs 子进程不允许使用的参数，持续运行的模式下子进程不会退出，-serve 的子进程还会监听同一个端口
+var batchForbiddenArgs = map[string]string{
+	"batch":     "nested -batch is not supported",
+	"serve":     "child processes would never exit",
+	"watch":     "child processes would never exit",
+	"tui":       "child processes would never exit",
+	"mongo-uri": "use mongo_uri instead",
+}
+
+// BatchConfig 批量运行的配置文件，用于在一次调用中审计多个集群
+//
+//	{
+//	  "concurrency": 2,
+//	  "clusters": [
+//	    {"name": "prod", "mongo_uri": "mongodb://db1.example.com:27017", "args": ["-collect-dbstats"]},
+//	    {"name": "test", "mongo_uri": "mongodb://db2.example.com:27017"}
+//	  ]
+//	}
+type BatchConfig struct {
+	// Concurrency 同时运行的集群数量，默认为 1，即依次运行
+	Concurrency int            `json:"concurrency"`
+	Clusters    []BatchCluster `json:"clusters"`
+}
+
+// BatchCluster 一个集群的配置，Args 为该集群额外的命令行参数，会覆盖批量运行时指定的同名参数
+type BatchCluster struct {
+	Name     string   `json:"name"`
+	MongoURI string   `json:"mongo_uri"`
+	Args     []string `json:"args"`
+}
+
+// BatchResult 一个集群的运行结果
+type BatchResult struct {
+	Cluster  BatchCluster
+	ExitCode int
+	Output   []byte
+	Err      error
+}
+
+// loadBatchConfig 读取并校验批量运行的配置文件
+func loadBatchConfig(filename string) (BatchConfig, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return BatchConfig{}, fmt.Errorf("read batch config failed: %w", err)
+	}
+
+	var conf BatchConfig
+	if err := json.Unmarshal(data, &conf); err != nil {
+		return BatchConfig{}, fmt.Errorf("invalid batch config: %w", err)
+	}
+
+	if len(conf.Clusters) == 0 {
+		return BatchConfig{}, fmt.Errorf("no cluster found in batch config %s", filename)
+	}
+
+	names := make(map[string]bool)
+	for _, cluster := range conf.Clusters {
+		if cluster.Name == "" || cluster.MongoURI == "" {
+			return BatchConfig{}, fmt.Errorf("name and mongo_uri are required for every cluster in batch config")
+		}
+
+		// 每个集群的历史版本使用 name 区分，重名会导致相互覆盖
+		if names[cluster.Name] {
+			return BatchConfig{}, fmt.Errorf("duplicate cluster name %s in batch config", cluster.Name)
+		}
+		names[cluster.Name] = true
+
+		for _, arg := range cluster.Args {
+			name := strings.SplitN(strings.TrimLeft(arg, "-"), "=", 2)[0]
+			if reason, ok := batchForbiddenArgs[name]; ok && strings.HasPrefix(arg, "-") {
+				return BatchConfig{}, fmt.Errorf("-%s is not allowed in args of cluster %s in batch config: %s", name, cluster.Name, reason)
+			}
+		}
+	}
+
+	if conf.Concurrency <= 0 {
+		conf.Concurrency = 1
+	}
+
+	return conf, nil
+}
+
+// runBatch 为每个集群启动一个独立的子进程运行，单个集群失败不影响其它集群，按照配置顺序输出每个集群的报告，返回合并后的退出状态码
+//
+// 子进程继承本次调用除 -batch 与 -mongo-uri 之外的所有参数，集群的 URI 通过环境变量 MONGODIFF_MONGO_URI 传递；任意集群失败时返回第一个失败集群的退出状态码，否则任意集群发生变化时返回 exitChanged
+func runBatch(out io.Writer, conf BatchConfig) int {
+	executable, err := os.Executable()
+	if err != nil {
+		panic(err)
+	}
+
+	commonArgs := make([]string, 0)
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name != "batch" && f.Name != "mongo-uri" {
+			commonArgs = append(commonArgs, fmt.Sprintf("-%s=%s", f.Name, f.Value.String()))
+		}
+	})
+
+	results := make([]BatchResult, len(conf.Clusters))
+	sem := make(chan struct{}, conf.Concurrency)
+	var wg sync.WaitGroup
+	for i, cluster := range conf.Clusters {
+		wg.Add(1)
+		go func(i int, cluster BatchCluster) {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			args := append(append([]string{}, commonArgs...), "-name="+cluster.Name)
+			results[i] = runBatchCluster(executable, cluster, append(args, cluster.Args...))
+		}(i, cluster)
+	}
+	wg.Wait()
+
+	exitCode := exitNoChange
+	failed := false
+	for _, res := range results {
+		_, _ = fmt.Fprintf(out, "=== %s: %s (exit %d) ===\n", res.Cluster.Name, batchStatus(res.ExitCode), res.ExitCode)
+		_, _ = out.Write(res.Output)
+		if res.Err != nil {
+			_, _ = fmt.Fprintf(out, "run failed: %s\n", redactURI(res.Err.Error()))
+		}
+		flushOutput(out)
+
+		switch {
+		case res.ExitCode == exitNoChange:
+		case res.ExitCode == exitChanged:
+			if !failed {
+				exitCode = exitChanged
+			}
+		case !failed:
+			failed, exitCode = true, res.ExitCode
+		}
+	}
+
+	return exitCode
+}
+
+func runBatchCluster(executable string, cluster BatchCluster, args []string) BatchResult {
+	cmd := exec.Command(executable, args...)
+	cmd.Env = append(os.Environ(), mongoURIEnv+"="+cluster.MongoURI)
+	output, err := cmd.CombinedOutput()
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return BatchResult{Cluster: cluster, ExitCode: exitNoChange, Output: output}
+	case errors.As(err, &exitErr):
+		return BatchResult{Cluster: cluster, ExitCode: exitErr.ExitCode(), Output: output}
+	default:
+		return BatchResult{Cluster: cluster, ExitCode: exitError, Output: output, Err: err}
+	}
+}
+
+func batchStatus(exitCode int) string {
+	switch exitCode {
+	case exitNoChange:
+		return "no change"
+	case exitChanged:
+		return "changed"
+	case exitConnectionError:
+		return "connection error"
+	case exitPartialError:
+		return "partial error"
+	default:
+		return "error"
+	}
+}
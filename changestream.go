@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ChangeStreamOptions 影响 change stream 消费方（CDC）的配置
+type ChangeStreamOptions struct {
+	// PreImageRetention pre-images 的保留时间（秒），为 off 时 pre-images 随 oplog 过期
+	PreImageRetention string `json:"pre_image_retention"`
+}
+
+type changeStreamOptionsResp struct {
+	ClusterParameters []struct {
+		PreAndPostImages struct {
+			ExpireAfterSeconds interface{} `bson:"expireAfterSeconds"`
+		} `bson:"preAndPostImages"`
+	} `bson:"clusterParameters"`
+}
+
+// ChangeStreamOptions 返回集群参数 changeStreamOptions 中的 change stream 配置，
+// 服务端不支持时（6.0 之前的版本、单机）返回 nil
+func (mm *MongoManager) ChangeStreamOptions(ctx context.Context) (*ChangeStreamOptions, error) {
+	var resp changeStreamOptionsResp
+	if err := mm.conn.Database("admin").RunCommand(ctx, bson.M{"getClusterParameter": "changeStreamOptions"}).Decode(&resp); err != nil {
+		if isCommandError(err, errCodeIllegalOperation, errCodeCommandNotFound) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	if len(resp.ClusterParameters) == 0 {
+		return nil, nil
+	}
+
+	retention := "off"
+	if expire := resp.ClusterParameters[0].PreAndPostImages.ExpireAfterSeconds; expire != nil {
+		retention = fmt.Sprintf("%v", expire)
+	}
+
+	return &ChangeStreamOptions{PreImageRetention: retention}, nil
+}
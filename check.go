@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// CheckResult 一个采集器在当前用户权限下的检查结果，Checked 为 false 时没有执行该采集器，OK 没有意义
+type CheckResult struct {
+	Collector string `json:"collector"`
+	Checked   bool   `json:"checked"`
+	OK        bool   `json:"ok"`
+	Error     string `json:"error,omitempty"`
+}
+
+// CheckReport -check 输出的连接与权限检查报告
+type CheckReport struct {
+	OK         bool          `json:"ok"`
+	Collectors []CheckResult `json:"collectors"`
+}
+
+// checkCollectors 连接 MongoDB 并依次执行所有启用的内置采集器，只记录每个采集器是否成功，不输出快照，也不写入 data-dir，
+// 无法连接时返回 ConnectionError；-collectors-file 中声明的采集器执行的命令由用户决定，可能修改数据，因此不执行，只记录为未检查
+func checkCollectors(mongoURI string) (CheckReport, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	connect, err := connectMongo(ctx, mongoURI)
+	if err != nil {
+		return CheckReport{}, err
+	}
+	defer connect.Disconnect(context.TODO())
+
+	mm := NewMongoManager(connect)
+	report := CheckReport{OK: true, Collectors: make([]CheckResult, 0)}
+
+	var snapshot Snapshot
+	// 选举统计的增量需要读写 data-dir 中上一次的结果，检查时只采集累计值
+	for _, c := range newCollectors(mongoURI, false) {
+		if c.Enabled != nil && !c.Enabled() {
+			continue
+		}
+
+		if c.Custom {
+			report.Collectors = append(report.Collectors, CheckResult{Collector: c.Name, Error: "custom collectors from -collectors-file are not executed in check mode"})
+			continue
+		}
+
+		result := CheckResult{Collector: c.Name, Checked: true, OK: true}
+		if err := c.Collect(ctx, mm, &snapshot); err != nil {
+			result.OK, result.Error = false, redactURI(err.Error())
+			report.OK = false
+		}
+
+		report.Collectors = append(report.Collectors, result)
+	}
+
+	return report, nil
+}
+
+// writeCheckReport 按照输出格式输出检查报告，json 格式输出为 JSON 文档，其它格式每个采集器输出一行 CHECK
+func writeCheckReport(out io.Writer, report CheckReport, format string) error {
+	if format == "json" {
+		buffer := bytes.NewBuffer(nil)
+		encoder := json.NewEncoder(buffer)
+		encoder.SetIndent("", jsonIndent)
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+
+		_, err := out.Write(applyJSONCase(buffer.Bytes()))
+		return err
+	}
+
+	for _, result := range report.Collectors {
+		line := fmt.Sprintf("CHECK: collector=%s, ok=%t", result.Collector, result.OK)
+		if !result.Checked {
+			line = fmt.Sprintf("CHECK: collector=%s, checked=false", result.Collector)
+		}
+
+		if result.Error != "" {
+			line += ", error=" + result.Error
+		}
+
+		if _, err := io.WriteString(out, line+"\n"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
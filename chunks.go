@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ChunkCount 一个集合在一个分片上的 chunk 数量
+type ChunkCount struct {
+	NS    string `json:"ns"`
+	Shard string `json:"shard"`
+	Count int64  `json:"count"`
+}
+
+type chunkGroup struct {
+	ID struct {
+		NS    string           `bson:"ns"`
+		UUID  primitive.Binary `bson:"uuid"`
+		Shard string           `bson:"shard"`
+	} `bson:"_id"`
+	Count int64 `bson:"count"`
+}
+
+type shardedCollection struct {
+	ID   string           `bson:"_id"`
+	UUID primitive.Binary `bson:"uuid"`
+}
+
+// ChunkCounts 按照集合与分片统计 config.chunks 中的 chunk 数量，按照 ns、shard 排序
+//
+// 5.0 之后 config.chunks 不再包含 ns 字段，需要通过 uuid 从 config.collections 中查找集合名称
+func (mm *MongoManager) ChunkCounts(ctx context.Context) ([]ChunkCount, error) {
+	config := mm.conn.Database("config")
+	cursor, err := config.Collection("chunks").Aggregate(ctx, bson.A{
+		bson.M{"$group": bson.M{
+			"_id":   bson.M{"ns": "$ns", "uuid": "$uuid", "shard": "$shard"},
+			"count": bson.M{"$sum": 1},
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []chunkGroup
+	if err := cursor.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+
+	if len(groups) == 0 {
+		return nil, nil
+	}
+
+	collCursor, err := config.Collection("collections").Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+
+	var colls []shardedCollection
+	if err := collCursor.All(ctx, &colls); err != nil {
+		return nil, err
+	}
+
+	namespaces := make(map[string]string)
+	for _, coll := range colls {
+		namespaces[hex.EncodeToString(coll.UUID.Data)] = coll.ID
+	}
+
+	counts := make([]ChunkCount, 0, len(groups))
+	for _, group := range groups {
+		ns := group.ID.NS
+		if ns == "" {
+			ns = namespaces[hex.EncodeToString(group.ID.UUID.Data)]
+		}
+
+		counts = append(counts, ChunkCount{NS: ns, Shard: group.ID.Shard, Count: group.Count})
+	}
+
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].NS != counts[j].NS {
+			return counts[i].NS < counts[j].NS
+		}
+
+		return counts[i].Shard < counts[j].Shard
+	})
+
+	return counts, nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// clusterParamAllowlist 需要采集的集群参数，其它参数（包括内部使用的参数）不输出
+var clusterParamAllowlist = map[string]bool{
+	"changeStreamOptions":               true,
+	"defaultMaxTimeMS":                  true,
+	"auditConfig":                       true,
+	"fleCompactionOptions":              true,
+	"pauseMigrationsDuringMultiUpdates": true,
+}
+
+// ClusterParam 通过 setClusterParameter 设置的集群参数
+type ClusterParam struct {
+	Name string `json:"name"`
+	// Value 参数值，为去掉 _id 与 clusterParameterTime 之后的紧凑 JSON
+	Value string `json:"value"`
+}
+
+type ClusterParamsResp struct {
+	ClusterParameters []bson.D `bson:"clusterParameters"`
+}
+
+// ClusterParams 返回 clusterParamAllowlist 中的集群参数，按照名称排序，服务端不支持 getClusterParameter 时（6.0 之前的版本、单机）返回 nil
+func (mm *MongoManager) ClusterParams(ctx context.Context) ([]ClusterParam, error) {
+	var resp ClusterParamsResp
+	if err := mm.conn.Database("admin").RunCommand(ctx, bson.M{"getClusterParameter": "*"}).Decode(&resp); err != nil {
+		if isCommandError(err, errCodeIllegalOperation, errCodeCommandNotFound) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	params := make([]ClusterParam, 0)
+	for _, doc := range resp.ClusterParameters {
+		var name string
+		value := make(bson.D, 0, len(doc))
+		for _, elem := range doc {
+			switch elem.Key {
+			case "_id":
+				name, _ = elem.Value.(string)
+			case "clusterParameterTime":
+			default:
+				value = append(value, elem)
+			}
+		}
+
+		if !clusterParamAllowlist[name] {
+			continue
+		}
+
+		data, err := bson.MarshalExtJSON(value, false, false)
+		if err != nil {
+			return nil, err
+		}
+
+		params = append(params, ClusterParam{Name: name, Value: string(data)})
+	}
+
+	sort.Slice(params, func(i, j int) bool { return params[i].Name < params[j].Name })
+	return params, nil
+}
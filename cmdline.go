@@ -0,0 +1,42 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// defaultCmdLineInclude 默认只输出网络、安全、复制、分片以及存储引擎相关的启动参数，
+// 日志路径、pid 文件等配置在不同主机之间、每次重启之后都可能不同，默认不输出
+const defaultCmdLineInclude = "net,security,replication,sharding,storage.engine"
+
+// CmdLineOption 一项启动参数，Key 为使用 . 连接的配置路径，如 net.bindIp
+type CmdLineOption struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// cmdLineOptions 展开 getCmdLineOpts 返回的所有配置，只保留 include 中的配置路径（为空时保留全部），按照 key 排序，密码等敏感信息只保留哈希值
+func cmdLineOptions(opts CmdLineOpts, include []string) []CmdLineOption {
+	options := make([]CmdLineOption, 0)
+	for key, value := range flattenSettings("", opts.ParsedRaw) {
+		if len(include) > 0 && !matchAnySubtree(key, include) {
+			continue
+		}
+
+		options = append(options, CmdLineOption{Key: key, Value: formatSettingValue(key, value)})
+	}
+
+	sort.Slice(options, func(i, j int) bool { return options[i].Key < options[j].Key })
+	return options
+}
+
+// matchAnySubtree 判断配置路径 key 是否位于 subtrees 中任意一个路径之下
+func matchAnySubtree(key string, subtrees []string) bool {
+	for _, subtree := range subtrees {
+		if key == subtree || strings.HasPrefix(key, subtree+".") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,585 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// Collector 采集器，负责采集一类信息并写入快照
+type Collector struct {
+	Name string
+	// Enabled 为 nil 时采集器始终启用
+	Enabled func() bool
+	Collect func(ctx context.Context, mm *MongoManager, snapshot *Snapshot) error
+	// Custom 为 true 时为 -collectors-file 中声明的采集器，执行的命令由用户决定
+	Custom bool
+}
+
+// CollectorError 单个采集器执行失败的错误信息
+type CollectorError struct {
+	Collector string `json:"collector"`
+	Error     string `json:"error"`
+}
+
+// PartialError 部分采集器执行失败，此时快照中仍然包含其它采集器采集到的数据
+type PartialError struct {
+	Errors []CollectorError
+}
+
+func (e PartialError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, ce := range e.Errors {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", ce.Collector, ce.Error))
+	}
+
+	return fmt.Sprintf("%d collector(s) failed: %s", len(e.Errors), strings.Join(msgs, "; "))
+}
+
+// isPartialError 判断 err 是否为部分采集失败
+func isPartialError(err error) bool {
+	var partialErr PartialError
+	return errors.As(err, &partialErr)
+}
+
+// UnhealthyError 存在状态异常的副本集成员（-fail-on-unhealthy）
+type UnhealthyError struct {
+	Members []UnhealthyMember
+}
+
+func (e UnhealthyError) Error() string {
+	names := make([]string, 0, len(e.Members))
+	for _, m := range e.Members {
+		names = append(names, fmt.Sprintf("%s(%s)", m.Name, m.State))
+	}
+
+	return fmt.Sprintf("unhealthy replica set members: %s", strings.Join(names, ", "))
+}
+
+// ConnectionError 无法连接到 MongoDB
+type ConnectionError struct {
+	err error
+}
+
+func (e ConnectionError) Error() string {
+	return redactURI(fmt.Sprintf("connect to mongodb failed: %v", e.err))
+}
+
+func (e ConnectionError) Unwrap() error {
+	return e.err
+}
+
+// isSoftError 判断 err 是否为不影响采集结果的错误，此时快照仍然可以正常输出与保存
+func isSoftError(err error) bool {
+	var unhealthyErr UnhealthyError
+	var certErr CertExpiryError
+	var opErr LongRunningOpError
+	return isPartialError(err) || errors.As(err, &unhealthyErr) || errors.As(err, &certErr) || errors.As(err, &opErr)
+}
+
+// newCollectors 返回所有的采集器，自定义采集器位于内置采集器之后，采集器按照顺序执行，后面的采集器可以使用前面采集器写入快照的数据，
+// withElectionDelta 为 true 时选举统计输出与上一次采集相比的增量，需要读写 data-dir 中上一次的结果
+func newCollectors(mongoURI string, withElectionDelta bool) []Collector {
+	collectors := []Collector{
+		{
+			Name: "databases",
+			Collect: func(ctx context.Context, mm *MongoManager, snapshot *Snapshot) (err error) {
+				if !collectDBSizes {
+					snapshot.Databases, err = mm.AllDatabaseNames(ctx)
+					return err
+				}
+
+				if snapshot.DatabaseSizes, err = mm.AllDatabases(ctx); err != nil {
+					return err
+				}
+
+				for _, db := range snapshot.DatabaseSizes {
+					snapshot.Databases = append(snapshot.Databases, db.Name)
+				}
+
+				return nil
+			},
+		},
+		{
+			Name: "users",
+			Collect: func(ctx context.Context, mm *MongoManager, snapshot *Snapshot) (err error) {
+				snapshot.Users, err = mm.AllUsers(ctx)
+				return err
+			},
+		},
+		{
+			Name: "roles",
+			Collect: func(ctx context.Context, mm *MongoManager, snapshot *Snapshot) (err error) {
+				snapshot.Roles, err = mm.AllRoles(ctx, filterDatabases(snapshot.Databases))
+				return err
+			},
+		},
+		{
+			Name:    "dbstats",
+			Enabled: func() bool { return collectDBStats },
+			Collect: func(ctx context.Context, mm *MongoManager, snapshot *Snapshot) error {
+				for _, name := range filterDatabases(snapshot.Databases) {
+					stats, err := mm.DBStats(ctx, name)
+					if err != nil {
+						return err
+					}
+
+					snapshot.DBStats = append(snapshot.DBStats, DBStats{
+						DB:          name,
+						Collections: stats.Collections,
+						DataSizeMB:  roundSizeMB(stats.DataSize),
+						Indexes:     stats.Indexes,
+					})
+				}
+
+				return nil
+			},
+		},
+		{
+			Name:    "indexes",
+			Enabled: func() bool { return collectIndexesEnabled || collectIndexSizes },
+			Collect: func(ctx context.Context, mm *MongoManager, snapshot *Snapshot) error {
+				return collectIndexes(ctx, mm, snapshot, collectIndexSizes)
+			},
+		},
+		{
+			Name:    "coll_counts",
+			Collect: collectCollCounts,
+		},
+		{
+			Name: "repl_config",
+			Collect: func(ctx context.Context, mm *MongoManager, snapshot *Snapshot) error {
+				conf, err := mm.Config(ctx)
+				if err != nil {
+					return err
+				}
+
+				snapshot.Members = conf.Members
+				snapshot.WriteConcernModes = writeConcernModes(conf.Settings.GetLastErrorModes)
+				snapshot.ReplSettings = replSettings(conf.Settings)
+				return nil
+			},
+		},
+		{
+			Name:    "host_info",
+			Enabled: func() bool { return collectHostInfo },
+			Collect: func(ctx context.Context, mm *MongoManager, snapshot *Snapshot) (err error) {
+				snapshot.Hosts, err = memberHostInfos(ctx, mongoURI, snapshot.Members)
+				return err
+			},
+		},
+		{
+			Name:    "tls_certs",
+			Enabled: func() bool { return collectTLSCerts },
+			Collect: func(ctx context.Context, mm *MongoManager, snapshot *Snapshot) (err error) {
+				snapshot.TLSCerts, err = tlsCerts(ctx, mongoURI, snapshot.Members)
+				return err
+			},
+		},
+		{
+			Name: "repl_status",
+			Collect: func(ctx context.Context, mm *MongoManager, snapshot *Snapshot) error {
+				replStat, err := mm.ReplStatus(ctx)
+				if err != nil {
+					return err
+				}
+
+				for _, stat := range replStat.Members {
+					snapshot.ReplStats = append(snapshot.ReplStats, ReplMemberStat{
+						ID:             stat.ID,
+						Name:           stat.Name,
+						State:          stat.StateStr,
+						Health:         stat.Health,
+						SyncSourceHost: stat.SyncSourceHost,
+						SyncingTo:      stat.SyncingTo,
+					})
+				}
+
+				return nil
+			},
+		},
+		{
+			Name: "mongos",
+			Collect: func(ctx context.Context, mm *MongoManager, snapshot *Snapshot) (err error) {
+				snapshot.Mongos, err = mm.AllMongos(ctx, mongosMaxPingAge)
+				return err
+			},
+		},
+		{
+			Name:    "chunks",
+			Enabled: func() bool { return collectChunks },
+			Collect: func(ctx context.Context, mm *MongoManager, snapshot *Snapshot) (err error) {
+				snapshot.Chunks, err = mm.ChunkCounts(ctx)
+				return err
+			},
+		},
+		{
+			Name: "zones",
+			Collect: func(ctx context.Context, mm *MongoManager, snapshot *Snapshot) error {
+				hello, err := mm.Hello(ctx)
+				if err != nil {
+					return err
+				}
+
+				// 只有 mongos 上的 config.tags 才是集群的 zone 配置
+				if !hello.IsMongos() {
+					return nil
+				}
+
+				snapshot.Zones, err = mm.Zones(ctx)
+				return err
+			},
+		},
+		{
+			Name:    "migrations",
+			Enabled: func() bool { return collectMigrations },
+			Collect: func(ctx context.Context, mm *MongoManager, snapshot *Snapshot) error {
+				hello, err := mm.Hello(ctx)
+				if err != nil {
+					return err
+				}
+
+				// config.changelog 只在分片集群中记录 chunk 迁移
+				if !hello.IsMongos() {
+					return nil
+				}
+
+				snapshot.Migrations, err = mm.Migrations(ctx, migrationWindow)
+				return err
+			},
+		},
+		{
+			Name: "hello",
+			Collect: func(ctx context.Context, mm *MongoManager, snapshot *Snapshot) error {
+				hello, err := mm.Hello(ctx)
+				if err != nil {
+					return err
+				}
+
+				snapshot.Hello = &hello
+				if collectTopologyVersion && hello.TopologyVersion != nil {
+					snapshot.TopologyVersion = &TopologyVersion{
+						ProcessID: hello.TopologyVersion.ProcessID.Hex(),
+						Counter:   hello.TopologyVersion.Counter,
+					}
+				}
+				return nil
+			},
+		},
+		{
+			Name:    "elections",
+			Enabled: func() bool { return collectElections },
+			Collect: func(ctx context.Context, mm *MongoManager, snapshot *Snapshot) error {
+				serverStatus, err := mm.ServerStatus(ctx)
+				if err != nil {
+					return err
+				}
+
+				// 非副本集成员没有选举统计
+				if serverStatus.ElectionMetrics == nil {
+					return nil
+				}
+
+				metrics := electionMetrics(*serverStatus.ElectionMetrics)
+				if withElectionDelta {
+					metrics = electionDelta(metrics)
+				}
+
+				snapshot.Elections = &metrics
+				return nil
+			},
+		},
+		{
+			Name: "oplog_size",
+			Collect: func(ctx context.Context, mm *MongoManager, snapshot *Snapshot) (err error) {
+				snapshot.OplogSize, err = mm.OplogSize(ctx)
+				return err
+			},
+		},
+		{
+			Name: "storage",
+			Collect: func(ctx context.Context, mm *MongoManager, snapshot *Snapshot) error {
+				serverStatus, err := mm.ServerStatus(ctx)
+				if err != nil {
+					return err
+				}
+
+				cmdLineOpts, err := mm.CmdLineOpts(ctx)
+				if err != nil {
+					return err
+				}
+
+				snapshot.Storage = StorageSettings{
+					Engine:         serverStatus.StorageEngine.Name,
+					JournalEnabled: cmdLineOpts.Parsed.Storage.Journal.Enabled,
+					DirectoryPerDB: cmdLineOpts.Parsed.Storage.DirectoryPerDB,
+					Persistent:     serverStatus.StorageEngine.Persistent,
+					ReadOnly:       serverStatus.StorageEngine.ReadOnly,
+				}
+
+				snapshot.Checkpoint, err = mm.Checkpoint(ctx)
+				return err
+			},
+		},
+		{
+			Name: "scripting",
+			Collect: func(ctx context.Context, mm *MongoManager, snapshot *Snapshot) error {
+				cmdLineOpts, err := mm.CmdLineOpts(ctx)
+				if err != nil {
+					return err
+				}
+
+				// 没有显式配置时服务端不会返回该参数，默认为开启
+				snapshot.Scripting = ScriptingSettings{JavascriptEnabled: cmdLineOpts.Parsed.Security.JavascriptEnabled}
+				return nil
+			},
+		},
+		{
+			Name: "free_monitoring",
+			Collect: func(ctx context.Context, mm *MongoManager, snapshot *Snapshot) (err error) {
+				snapshot.FreeMonitoring, err = mm.FreeMonitoring(ctx)
+				return err
+			},
+		},
+		{
+			Name: "auth_providers",
+			Collect: func(ctx context.Context, mm *MongoManager, snapshot *Snapshot) error {
+				cmdLineOpts, err := mm.CmdLineOpts(ctx)
+				if err != nil {
+					return err
+				}
+
+				snapshot.AuthProviders = authProviderSettings(cmdLineOpts)
+				return nil
+			},
+		},
+		{
+			Name:    "cmdline",
+			Enabled: func() bool { return collectCmdLine },
+			Collect: func(ctx context.Context, mm *MongoManager, snapshot *Snapshot) error {
+				cmdLineOpts, err := mm.CmdLineOpts(ctx)
+				if err != nil {
+					return err
+				}
+
+				// * 表示输出全部启动参数，包括路径、pid 文件等每台主机、每次重启都可能不同的配置
+				include := make([]string, 0)
+				for _, subtree := range strings.Split(cmdLineInclude, ",") {
+					if subtree = strings.TrimSpace(subtree); subtree != "" && subtree != "*" {
+						include = append(include, subtree)
+					}
+				}
+
+				snapshot.CmdLine = cmdLineOptions(cmdLineOpts, include)
+				return nil
+			},
+		},
+		{
+			Name:    "parameters",
+			Enabled: func() bool { return len(parameterNames) > 0 },
+			Collect: func(ctx context.Context, mm *MongoManager, snapshot *Snapshot) (err error) {
+				snapshot.Parameters, err = mm.Parameters(ctx, parameterNames)
+				return err
+			},
+		},
+		{
+			Name:    "commands",
+			Enabled: func() bool { return collectCommands },
+			Collect: func(ctx context.Context, mm *MongoManager, snapshot *Snapshot) (err error) {
+				snapshot.Commands, err = mm.CommandAvailability(ctx, parseProbeCommands(probeCommandsExpr))
+				return err
+			},
+		},
+		{
+			Name: "cluster_params",
+			Collect: func(ctx context.Context, mm *MongoManager, snapshot *Snapshot) (err error) {
+				snapshot.ClusterParams, err = mm.ClusterParams(ctx)
+				return err
+			},
+		},
+		{
+			Name: "transactions",
+			Collect: func(ctx context.Context, mm *MongoManager, snapshot *Snapshot) error {
+				hello, err := mm.Hello(ctx)
+				if err != nil {
+					return err
+				}
+
+				if !hello.supportsTransactions() {
+					return nil
+				}
+
+				snapshot.Transactions, err = mm.Transactions(ctx)
+				return err
+			},
+		},
+		{
+			Name:    "current_op",
+			Enabled: func() bool { return collectCurrentOp },
+			Collect: func(ctx context.Context, mm *MongoManager, snapshot *Snapshot) (err error) {
+				snapshot.CurrentOp, err = mm.CurrentOp(ctx)
+				return err
+			},
+		},
+		{
+			Name: "change_stream",
+			Collect: func(ctx context.Context, mm *MongoManager, snapshot *Snapshot) (err error) {
+				snapshot.ChangeStream, err = mm.ChangeStreamOptions(ctx)
+				return err
+			},
+		},
+	}
+
+	for _, c := range customCollectors {
+		collectors = append(collectors, c.Collector())
+	}
+
+	for _, a := range aggregationCollectors {
+		collectors = append(collectors, a.Collector())
+	}
+
+	return collectors
+}
+
+// filterDatabases 过滤掉匹配 -exclude-db 的数据库，指定了 -database 时只保留该数据库，用于按数据库执行的采集器
+func filterDatabases(names []string) []string {
+	if scopeDatabase != "" {
+		for _, name := range names {
+			if name == scopeDatabase {
+				return []string{name}
+			}
+		}
+
+		return []string{}
+	}
+
+	if len(excludeDBPatterns) == 0 {
+		return names
+	}
+
+	result := make([]string, 0, len(names))
+	for _, name := range names {
+		if !matchAnyPattern(excludeDBPatterns, name) {
+			result = append(result, name)
+		}
+	}
+
+	return result
+}
+
+// matchAnyPattern 判断 name 是否匹配任意一个通配符模式（path.Match 语法）
+func matchAnyPattern(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// roundSizeMB 将字节数转换为 MB，并保留两位有效数字，避免数据量的细微变化产生差异
+func roundSizeMB(bytes float64) float64 {
+	return roundSignificant(bytes / 1024 / 1024)
+}
+
+// roundSignificant 保留两位有效数字
+func roundSignificant(v float64) float64 {
+	rounded, _ := strconv.ParseFloat(strconv.FormatFloat(v, 'g', 2, 64), 64)
+	return rounded
+}
+
+// connectMongo 连接 MongoDB 并检查主节点是否可用，无法连接时返回 ConnectionError
+func connectMongo(ctx context.Context, mongoURI string) (*mongo.Client, error) {
+	clientOption, err := newClientOptions(mongoURI)
+	if err != nil {
+		return nil, err
+	}
+
+	connect, err := mongo.Connect(ctx, clientOption)
+	if err != nil {
+		return nil, ConnectionError{err: err}
+	}
+
+	if err := connect.Ping(ctx, readpref.Primary()); err != nil {
+		_ = connect.Disconnect(context.TODO())
+		return nil, ConnectionError{err: err}
+	}
+
+	return connect, nil
+}
+
+// collect 连接 MongoDB 并执行所有的采集器
+//
+// 单个采集器失败不会中断采集，失败信息记录在快照的 Errors 中，此时同时返回快照与 PartialError；
+// 开启 -fail-on-unhealthy 且存在状态异常的成员时，同时返回快照与 UnhealthyError；
+// 指定了 -tls-cert-warn-days 且存在即将过期的证书时，同时返回快照与 CertExpiryError
+func collect(mongoURI string) (*Snapshot, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	connect, err := connectMongo(ctx, mongoURI)
+	if err != nil {
+		return nil, err
+	}
+	defer connect.Disconnect(context.TODO())
+
+	mm := NewMongoManager(connect)
+	if err := checkPrivilegesOnce(ctx, mm, strictPrivileges); err != nil {
+		return nil, err
+	}
+
+	var snapshot Snapshot
+	for _, c := range newCollectors(mongoURI, electionDeltaEnabled) {
+		if c.Enabled != nil && !c.Enabled() {
+			continue
+		}
+
+		startTime := time.Now()
+		if err := c.Collect(ctx, mm, &snapshot); err != nil {
+			snapshot.Errors = append(snapshot.Errors, CollectorError{Collector: c.Name, Error: err.Error()})
+		}
+
+		if collectorTiming {
+			log.Printf("collector %s took %s", c.Name, time.Since(startTime))
+		}
+	}
+
+	if memberFilter != nil {
+		memberFilter.Apply(&snapshot)
+	}
+
+	truncateSnapshotValues(&snapshot, int(maxValueLength))
+
+	snapshot.Summary = snapshot.Summarize()
+	snapshot.Unhealthy = snapshot.UnhealthyMembers()
+	snapshot.NoElect = snapshot.NoElectMembers()
+
+	if len(snapshot.Errors) > 0 {
+		return &snapshot, PartialError{Errors: snapshot.Errors}
+	}
+
+	if failOnUnhealthy && len(snapshot.Unhealthy) > 0 {
+		return &snapshot, UnhealthyError{Members: snapshot.Unhealthy}
+	}
+
+	if tlsCertWarnDays > 0 {
+		if expiring := snapshot.ExpiringTLSCerts(tlsCertWarnDays); len(expiring) > 0 {
+			return &snapshot, CertExpiryError{Certs: expiring}
+		}
+	}
+
+	if currentOpWarnSecs > 0 && snapshot.CurrentOp != nil && snapshot.CurrentOp.LongestSecs > int64(currentOpWarnSecs) {
+		return &snapshot, LongRunningOpError{Summary: *snapshot.CurrentOp}
+	}
+
+	return &snapshot, nil
+}
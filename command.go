@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// defaultProbeCommands 默认检查的敏感命令，这些命令可以执行任意代码、读取日志或者影响服务端的运行状态
+var defaultProbeCommands = []string{
+	"eval",
+	"getLog",
+	"setParameter",
+	"configureFailPoint",
+	"sleep",
+	"godinsert",
+	"emptycapped",
+	"fsync",
+	"shutdown",
+	"logRotate",
+	"copydb",
+	"mapReduce",
+}
+
+// CommandAvailability 命令在服务端是否可用
+type CommandAvailability struct {
+	Name      string `json:"name"`
+	Available bool   `json:"available"`
+}
+
+type listCommandsResp struct {
+	Commands bson.M `bson:"commands"`
+}
+
+// parseProbeCommands 解析 -probe-commands 参数，为空时使用 defaultProbeCommands
+func parseProbeCommands(expr string) []string {
+	names := make([]string, 0)
+	for _, name := range strings.Split(expr, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+
+	if len(names) == 0 {
+		return defaultProbeCommands
+	}
+
+	return names
+}
+
+// CommandAvailability 通过 listCommands 检查 names 中的命令是否可用，不会实际执行这些命令，结果按照 names 的顺序排列
+//
+// 通过 --setParameter enableTestCommands=0 禁用或者在新版本中被移除的命令不会出现在 listCommands 的结果中
+func (mm *MongoManager) CommandAvailability(ctx context.Context, names []string) ([]CommandAvailability, error) {
+	var resp listCommandsResp
+	if err := mm.conn.Database("admin").RunCommand(ctx, bson.M{"listCommands": 1}).Decode(&resp); err != nil {
+		return nil, err
+	}
+
+	result := make([]CommandAvailability, 0, len(names))
+	for _, name := range names {
+		_, ok := resp.Commands[name]
+		result = append(result, CommandAvailability{Name: name, Available: ok})
+	}
+
+	return result, nil
+}
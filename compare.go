@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+)
+
+var (
+	// textSnapshotRegexp 文本格式快照的第一行，如 SUMMARY: databases=3
+	textSnapshotRegexp = regexp.MustCompile(`^[A-Z][A-Z0-9_]*: `)
+	// yamlSnapshotRegexp YAML 文档的第一行，如 --- 或者 summary:
+	yamlSnapshotRegexp = regexp.MustCompile(`^(---\s*$|[a-z_][a-z0-9_]*:)`)
+)
+
+// detectSnapshotFormat 识别快照文件的格式，返回 text 或 json
+func detectSnapshotFormat(content string) (string, error) {
+	trimmed := strings.TrimSpace(content)
+	if trimmed == "" {
+		return "text", nil
+	}
+
+	if json.Valid([]byte(trimmed)) {
+		return "json", nil
+	}
+
+	firstLine := strings.SplitN(trimmed, "\n", 2)[0]
+	if textSnapshotRegexp.MatchString(firstLine) {
+		return "text", nil
+	}
+
+	if yamlSnapshotRegexp.MatchString(firstLine) {
+		return "", fmt.Errorf("yaml snapshots are not supported, convert them to json first")
+	}
+
+	return "", fmt.Errorf("unrecognized snapshot format")
+}
+
+// compareFiles 离线对比两个快照文件，两个文件需要为相同的格式，返回 unified diff
+func compareFiles(differ *Differ, file1, file2 string) (string, error) {
+	contents := make([]string, 0, 2)
+	formats := make([]string, 0, 2)
+	for _, f := range []string{file1, file2} {
+		data, err := ioutil.ReadFile(f)
+		if err != nil {
+			return "", err
+		}
+
+		format, err := detectSnapshotFormat(string(data))
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", f, err)
+		}
+
+		contents, formats = append(contents, string(data)), append(formats, format)
+	}
+
+	if formats[0] != formats[1] {
+		return "", fmt.Errorf("can not compare a %s snapshot (%s) with a %s snapshot (%s)", formats[0], file1, formats[1], file2)
+	}
+
+	return differ.DiffText(file1, contents[0], file2, contents[1]), nil
+}
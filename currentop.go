@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// currentOpExcludePatterns 匹配 CURRENTOP 在 text 与 json（包括不缩进的 json 以及 -json-case camel）格式中对应的内容，默认不参与 diff
+var currentOpExcludePatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?m)^CURRENTOP: .*(\n|$)`),
+	regexp.MustCompile(`"current_?[oO]p":\s*"[^"]*",?\s*`),
+}
+
+// CurrentOpSummary 采集时正在执行的操作汇总，只反映采集时刻的状态，默认不参与 diff
+type CurrentOpSummary struct {
+	// Active 正在执行的操作数量
+	Active int
+	// LongestSecs 执行时间最长的操作已经执行的时间，单位秒
+	LongestSecs int64
+}
+
+func (s CurrentOpSummary) String() string {
+	return fmt.Sprintf("active=%d, longestSecs=%d", s.Active, s.LongestSecs)
+}
+
+// MarshalText 在 json 格式中输出为字符串，方便从 diff 中排除
+func (s CurrentOpSummary) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText 解析 MarshalText 输出的内容，用于读取 json 格式的快照
+func (s *CurrentOpSummary) UnmarshalText(text []byte) error {
+	if _, err := fmt.Sscanf(string(text), "active=%d, longestSecs=%d", &s.Active, &s.LongestSecs); err != nil {
+		return fmt.Errorf("invalid current op summary %q: %w", text, err)
+	}
+
+	return nil
+}
+
+// LongRunningOpError 存在执行时间超过 -currentop-warn-secs 的操作
+type LongRunningOpError struct {
+	Summary CurrentOpSummary
+}
+
+func (e LongRunningOpError) Error() string {
+	return fmt.Sprintf("operation running for %ds, longer than %ds (%d active)", e.Summary.LongestSecs, currentOpWarnSecs, e.Summary.Active)
+}
+
+type currentOpResp struct {
+	InProg []struct {
+		SecsRunning int64 `bson:"secs_running"`
+	} `bson:"inprog"`
+}
+
+// CurrentOp 返回当前正在执行的操作汇总（currentOp），不包含空闲的连接
+func (mm *MongoManager) CurrentOp(ctx context.Context) (*CurrentOpSummary, error) {
+	var resp currentOpResp
+	if err := mm.conn.Database("admin").RunCommand(ctx, bson.D{{Key: "currentOp", Value: 1}, {Key: "active", Value: true}}).Decode(&resp); err != nil {
+		return nil, err
+	}
+
+	summary := &CurrentOpSummary{Active: len(resp.InProg)}
+	for _, op := range resp.InProg {
+		if op.SecsRunning > summary.LongestSecs {
+			summary.LongestSecs = op.SecsRunning
+		}
+	}
+
+	return summary, nil
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"text/template"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// CustomCollectorsConfig 自定义采集器配置文件（-collectors-file），每个采集器执行一个命令，并使用模板将结果格式化为输出行
+//
+//	{
+//	  "collectors": [
+//	    {
+//	      "name": "balancer",
+//	      "database": "admin",
+//	      "command": {"balancerStatus": 1},
+//	      "template": "BALANCER: mode={{.mode}}"
+//	    }
+//	  ],
+//	  "aggregations": [
+//	    {
+//	      "label": "orders_by_status",
+//	      "database": "app",
+//	      "collection": "orders",
+//	      "pipeline": [{"$group": {"_id": "$status", "count": {"$sum": 1}}}, {"$sort": {"_id": 1}}],
+//	      "limit": 100
+//	    }
+//	  ]
+//	}
+//
+// command 使用扩展 JSON 格式，字段顺序保持不变，第一个字段为命令名称；template 为 text/template 模板，
+// 以命令返回的文档作为数据，每一行输出一行快照内容，空行被忽略，可以使用 format 函数格式化嵌套文档与数组；
+// aggregations 中的每个聚合采集器在集合上执行 pipeline（扩展 JSON 格式），每个结果文档输出一行 AGG[label]
+type CustomCollectorsConfig struct {
+	Collectors   []CustomCollector      `json:"collectors"`
+	Aggregations []AggregationCollector `json:"aggregations"`
+}
+
+// CustomCollector 一个自定义采集器的声明，Database 为空时在 admin 数据库上执行命令
+type CustomCollector struct {
+	Name     string          `json:"name"`
+	Database string          `json:"database"`
+	Command  json.RawMessage `json:"command"`
+	Template string          `json:"template"`
+
+	command bson.D
+	tmpl    *template.Template
+}
+
+// CustomLine 自定义采集器输出的一行
+type CustomLine struct {
+	Collector string `json:"collector"`
+	Line      string `json:"line"`
+}
+
+// customCollectors 从 -collectors-file 加载的自定义采集器，在内置采集器之后执行
+var customCollectors []CustomCollector
+
+// aggregationCollectors 从 -collectors-file 加载的聚合采集器，在自定义采集器之后执行
+var aggregationCollectors []AggregationCollector
+
+var customTemplateFuncs = template.FuncMap{
+	"format": func(value interface{}) string {
+		return formatSettingValue("", value)
+	},
+}
+
+// loadCustomCollectors 读取并校验自定义采集器配置文件
+func loadCustomCollectors(filename string) (*CustomCollectorsConfig, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("read collectors file failed: %w", err)
+	}
+
+	var conf CustomCollectorsConfig
+	if err := json.Unmarshal(data, &conf); err != nil {
+		return nil, fmt.Errorf("invalid collectors file: %w", err)
+	}
+
+	if len(conf.Collectors) == 0 && len(conf.Aggregations) == 0 {
+		return nil, fmt.Errorf("no collector found in collectors file %s", filename)
+	}
+
+	// 自定义采集器的名称会出现在 ERROR 行中，不能与内置采集器重名
+	names := make(map[string]bool)
+	for _, c := range newCollectors("", false) {
+		names[c.Name] = true
+	}
+
+	for i := range conf.Collectors {
+		c := &conf.Collectors[i]
+		if c.Name == "" {
+			return nil, fmt.Errorf("name is required for collector #%d in collectors file", i+1)
+		}
+
+		if names[c.Name] {
+			return nil, fmt.Errorf("duplicate collector name %s in collectors file", c.Name)
+		}
+		names[c.Name] = true
+
+		if c.Database == "" {
+			c.Database = "admin"
+		}
+
+		if len(c.Command) == 0 {
+			return nil, fmt.Errorf("command is required for collector %s", c.Name)
+		}
+
+		if err := bson.UnmarshalExtJSON(c.Command, false, &c.command); err != nil {
+			return nil, fmt.Errorf("invalid command for collector %s: %w", c.Name, err)
+		}
+
+		if len(c.command) == 0 {
+			return nil, fmt.Errorf("command for collector %s is empty", c.Name)
+		}
+
+		if strings.TrimSpace(c.Template) == "" {
+			return nil, fmt.Errorf("template is required for collector %s", c.Name)
+		}
+
+		if c.tmpl, err = template.New(c.Name).Funcs(customTemplateFuncs).Option("missingkey=zero").Parse(c.Template); err != nil {
+			return nil, fmt.Errorf("invalid template for collector %s: %w", c.Name, err)
+		}
+	}
+
+	for i := range conf.Aggregations {
+		a := &conf.Aggregations[i]
+		if err := a.validate(); err != nil {
+			return nil, err
+		}
+
+		if names["agg:"+a.Label] {
+			return nil, fmt.Errorf("duplicate aggregation label %s in collectors file", a.Label)
+		}
+		names["agg:"+a.Label] = true
+	}
+
+	return &conf, nil
+}
+
+// Collector 将自定义采集器转换为采集器
+func (c CustomCollector) Collector() Collector {
+	return Collector{
+		Name:   c.Name,
+		Custom: true,
+		Collect: func(ctx context.Context, mm *MongoManager, snapshot *Snapshot) error {
+			var result bson.M
+			if err := mm.conn.Database(c.Database).RunCommand(ctx, c.command).Decode(&result); err != nil {
+				return err
+			}
+
+			var buffer bytes.Buffer
+			if err := c.tmpl.Execute(&buffer, result); err != nil {
+				return err
+			}
+
+			for _, line := range strings.Split(buffer.String(), "\n") {
+				line = strings.TrimRight(line, " \t\r")
+				if line == "" {
+					continue
+				}
+
+				snapshot.Custom = append(snapshot.Custom, CustomLine{Collector: c.Name, Line: redactURI(line)})
+			}
+
+			return nil
+		},
+	}
+}
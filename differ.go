@@ -0,0 +1,611 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mylxsw/go-utils/diff"
+)
+
+// Differ 将当前状态与最后一次保存的状态进行对比，存储的文件布局与 go-utils/diff 保持一致：
+//
+//	{name}.idx                    最后一次保存的状态文件名
+//	{name}.{timestamp}.stat       状态文件
+//	{name}.{timestamp}.stat.diff  该状态与上一个状态的差异
+//	{name}.{timestamp}.stat.msg   保存该状态时附加的说明信息（可选）
+//	{name}.{timestamp}.stat.run   保存该状态的运行标识
+//	{name}.{timestamp}.stat.sha256 状态文件内容的 sha256（可选，StoreHash）
+//	{name}.{timestamp}.stat.interesting 变化的行数达到阈值的重要版本标记，内容为变化的行数（可选，KeepInteresting）
+//
+// 状态文件名可以通过 FilenameFormat 修改，diff 与说明信息文件始终为状态文件名加上 .diff、.msg 后缀
+type Differ struct {
+	fs       diff.FS
+	dataDir  string
+	differ   *diff.Differ
+	reverse  bool
+	clock    Clock
+	message  string
+	against  int
+	common   int
+	keepDays uint
+	format   string
+	// ignoreWhitespace 为 true 时对比前先规范化空白字符，保存的状态不受影响
+	ignoreWhitespace bool
+	// tolerance 不为空时数字字段值在容忍范围内的变化不视为变化，保存的状态不受影响
+	tolerance *NumericTolerance
+	// semanticJSON 不为空时按照结构对比 JSON 文档，输出基于路径的变更报告
+	semanticJSON *SemanticJSON
+	// exclude 匹配的内容不参与对比，保存的状态不受影响
+	exclude []*regexp.Regexp
+	// storeHash 为 true 时保存状态的同时保存内容的 sha256，内容与最后一次保存的状态相同时跳过对比
+	storeHash bool
+	// interestingThreshold 不为 0 时，变化的行数达到该值的版本在保存时标记为重要版本
+	interestingThreshold uint
+	// interestingCap 按照数量清理时，最多保留多少个最新的重要版本
+	interestingCap uint
+}
+
+// WithMessage 设置保存版本时附加的说明信息，说明信息单独存储，不参与差异对比
+func (d *Differ) WithMessage(message string) *Differ {
+	d.message = message
+	return d
+}
+
+// KeepDays 设置版本的保留天数，为 0 时只按照数量清理
+func (d *Differ) KeepDays(days uint) *Differ {
+	d.keepDays = days
+	return d
+}
+
+// IgnoreWhitespace 设置对比时是否忽略空白字符的差异，每一行中连续的空白字符视为一个空格，并忽略行首行尾的空白字符
+func (d *Differ) IgnoreWhitespace(ignore bool) *Differ {
+	d.ignoreWhitespace = ignore
+	return d
+}
+
+// WithNumericTolerance 设置数值变化的容忍范围，为 nil 时不启用
+func (d *Differ) WithNumericTolerance(tolerance *NumericTolerance) *Differ {
+	d.tolerance = tolerance
+	return d
+}
+
+// SemanticJSON 设置按照结构对比 JSON 文档，为 nil 时按照文本对比
+func (d *Differ) SemanticJSON(sj *SemanticJSON) *Differ {
+	d.semanticJSON = sj
+	return d
+}
+
+// Exclude 设置不参与对比的内容，用于只反映采集时刻状态的数据，为空时所有内容都参与对比
+func (d *Differ) Exclude(patterns []*regexp.Regexp) *Differ {
+	d.exclude = patterns
+	return d
+}
+
+// excludeVolatile 去掉 s 中不参与对比的内容
+func (d *Differ) excludeVolatile(s string) string {
+	for _, pattern := range d.exclude {
+		s = pattern.ReplaceAllString(s, "")
+	}
+
+	return s
+}
+
+// StoreHash 设置保存状态时是否同时保存内容的 sha256
+func (d *Differ) StoreHash(enabled bool) *Differ {
+	d.storeHash = enabled
+	return d
+}
+
+// KeepInteresting 设置重要版本的保留策略：变化的行数（新增与删除）达到 threshold 的版本在保存时标记为重要版本，
+// 按照数量清理时最新的 cap 个重要版本不会被清理，threshold 为 0 时不启用
+func (d *Differ) KeepInteresting(threshold, cap uint) *Differ {
+	d.interestingThreshold, d.interestingCap = threshold, cap
+	return d
+}
+
+// tolerate 在 target 中将数值变化在容忍范围内的行替换为 original 中对应的行
+func (d *Differ) tolerate(original, target string) string {
+	if d.tolerance == nil {
+		return target
+	}
+
+	return d.tolerance.tolerate(original, target)
+}
+
+// normalize 对比前规范化文档内容
+func (d *Differ) normalize(s string) string {
+	s = d.excludeVolatile(s)
+	if d.semanticJSON != nil {
+		return d.semanticJSON.Normalize(s)
+	}
+
+	if !d.ignoreWhitespace {
+		return s
+	}
+
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.Join(strings.Fields(line), " ")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// defaultVersionFilenameFormat 默认的状态文件名格式，与 go-utils/diff 保持一致
+const defaultVersionFilenameFormat = "{name}.{timestamp}.stat"
+
+// FilenameFormat 设置状态文件名格式，支持 {name}、{timestamp}、{seq} 占位符，格式需要先通过 validateVersionFilenameFormat 校验
+func (d *Differ) FilenameFormat(format string) *Differ {
+	d.format = format
+	return d
+}
+
+// validateVersionFilenameFormat 校验状态文件名格式：必须包含 {name} 与 {timestamp}，保证文件名唯一且可以按照时间排序，
+// {seq} 为可选的递增序号，固定为 6 位以保证按照文件名排序时顺序正确
+func validateVersionFilenameFormat(format string) error {
+	for _, placeholder := range []string{"{name}", "{timestamp}"} {
+		if strings.Count(format, placeholder) != 1 {
+			return fmt.Errorf("invalid version filename format %q: %s must appear exactly once", format, placeholder)
+		}
+	}
+
+	if strings.Count(format, "{seq}") > 1 {
+		return fmt.Errorf("invalid version filename format %q: {seq} can appear at most once", format)
+	}
+
+	if strings.ContainsAny(format, `/\`) {
+		return fmt.Errorf("invalid version filename format %q: path separator is not allowed", format)
+	}
+
+	for _, suffix := range []string{".idx", ".diff", ".msg", ".run", ".sha256", ".interesting"} {
+		if strings.HasSuffix(format, suffix) {
+			return fmt.Errorf("invalid version filename format %q: %s suffix is reserved", format, suffix)
+		}
+	}
+
+	return nil
+}
+
+// versionFile 按照文件名格式生成状态文件名
+func (d *Differ) versionFile(name, timestamp string, seq int) string {
+	return strings.NewReplacer("{name}", name, "{timestamp}", timestamp, "{seq}", fmt.Sprintf("%06d", seq)).Replace(d.format)
+}
+
+// versionFileRegexp 返回匹配 name 对应状态文件名的正则表达式
+func (d *Differ) versionFileRegexp(name string) *regexp.Regexp {
+	pattern := strings.NewReplacer(
+		regexp.QuoteMeta("{name}"), regexp.QuoteMeta(name),
+		regexp.QuoteMeta("{timestamp}"), `(?P<timestamp>\d{14})`,
+		regexp.QuoteMeta("{seq}"), `(?P<seq>\d+)`,
+	).Replace(regexp.QuoteMeta(d.format))
+
+	return regexp.MustCompile("^" + pattern + "$")
+}
+
+// nextVersionFile 返回 name 下一个版本的文件名；时间戳不晚于最后一个版本时（同一秒内多次保存，或者多次使用相同的 -now）
+// 顺延到最后一个版本之后一秒，避免覆盖已有的版本，同时保证按照时间排序的最后一个版本与 .idx 一致
+func (d *Differ) nextVersionFile(name string) string {
+	timestamp := d.clock.Now().In(time.Local).Format("20060102150405")
+
+	versions, err := d.Versions(name)
+	if err != nil || len(versions) == 0 {
+		return d.versionFile(name, timestamp, 1)
+	}
+
+	latest := versions[len(versions)-1]
+	if timestamp <= latest.Timestamp {
+		adjusted := latest.Time().Add(time.Second).Format("20060102150405")
+		if timestamp < latest.Timestamp {
+			log.Printf("version timestamp %s is earlier than the latest saved version %s, saved as %s", timestamp, latest.File, adjusted)
+		}
+
+		timestamp = adjusted
+	}
+
+	return d.versionFile(name, timestamp, latest.Seq+1)
+}
+
+// Clock 时间来源，用于生成版本文件名中的时间戳
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock 使用系统当前时间
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// FixedClock 始终返回固定的时间，用于测试以及回放历史采集结果
+type FixedClock time.Time
+
+func (c FixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+// NewDiffer create a new Differ
+func NewDiffer(fs diff.FS, dataDir string, contextLine int) *Differ {
+	return &Differ{fs: fs, dataDir: dataDir, differ: diff.NewDiffer(fs, dataDir, contextLine), clock: SystemClock{}, format: defaultVersionFilenameFormat}
+}
+
+// WithClock 设置生成版本文件名时使用的时间来源
+func (d *Differ) WithClock(clock Clock) *Differ {
+	d.clock = clock
+	return d
+}
+
+// Reverse 设置是否反转 diff 的方向，反转后新状态作为 before，旧状态作为 after
+func (d *Differ) Reverse(reverse bool) *Differ {
+	d.reverse = reverse
+	return d
+}
+
+// DiffAgainst 设置与之前第几个版本进行对比，默认为 1，即与最后一次保存的版本对比
+// 是否保存新版本始终取决于当前状态与最后一次保存的版本是否一致
+func (d *Differ) DiffAgainst(n int) *Differ {
+	d.against = n
+	return d
+}
+
+// DiffCommon 设置与最近 n 个版本中共同存在的行进行对比，只在某个版本中短暂出现或消失的行不会被视为基线的一部分，
+// 从而减少反复变化的字段带来的噪音，为 0 时不启用；是否保存新版本不受影响
+func (d *Differ) DiffCommon(n int) *Differ {
+	d.common = n
+	return d
+}
+
+// DiffLatest 将当前文档与最后一次保存的文档对比
+func (d *Differ) DiffLatest(name string, target string) Diff {
+	var original []byte
+	idx, _ := d.fs.ReadFile(filepath.Join(d.dataDir, name+".idx"))
+	if string(idx) != "" {
+		idxFilepath := filepath.Join(d.dataDir, string(idx))
+		if d.fs.Exist(idxFilepath) {
+			original, _ = d.fs.ReadFile(idxFilepath)
+		}
+	}
+
+	// 与最后一次保存的状态对比时，内容的 sha256 相同说明没有任何变化，不需要执行对比
+	if d.storeHash && d.common == 0 && d.against <= 1 && string(idx) != "" {
+		if hash, err := d.fs.ReadFile(filepath.Join(d.dataDir, string(idx)+".sha256")); err == nil && string(hash) == checksum(target) {
+			return Diff{differ: d, name: name, original: string(original), target: target}
+		}
+	}
+
+	res := Diff{differ: d, name: name, original: string(original), target: target, changed: d.normalize(string(original)) != d.normalize(d.tolerate(string(original), target))}
+	switch {
+	case d.common > 0:
+		res.diff = d.diff(fmt.Sprintf("%s.common-%d", name, d.common), d.commonLines(name, d.common), name+".new", target)
+	case d.against <= 1:
+		res.diff = d.diff(string(idx), string(original), name+".new", target)
+	default:
+		baseName, base := d.versionAgo(name, d.against)
+		res.diff = d.diff(baseName, base, name+".new", target)
+	}
+
+	return res
+}
+
+// commonLines 返回最近 n 个版本中都存在的行，按照最新版本中的顺序排列
+func (d *Differ) commonLines(name string, n int) string {
+	versions, err := d.Versions(name)
+	if err != nil || len(versions) == 0 {
+		return ""
+	}
+
+	if len(versions) > n {
+		versions = versions[len(versions)-n:]
+	}
+
+	counts := make(map[string]int)
+	var latest []string
+	for _, version := range versions {
+		data, _ := d.fs.ReadFile(filepath.Join(d.dataDir, version.File))
+		lines := strings.SplitAfter(string(data), "\n")
+
+		seen := make(map[string]bool)
+		for _, line := range lines {
+			if !seen[line] {
+				seen[line] = true
+				counts[line]++
+			}
+		}
+
+		latest = lines
+	}
+
+	var sb strings.Builder
+	for _, line := range latest {
+		if counts[line] == len(versions) {
+			sb.WriteString(line)
+		}
+	}
+
+	return sb.String()
+}
+
+// versionAgo 返回之前第 n 个版本的文件名与内容，版本数量不足时返回最早的版本
+func (d *Differ) versionAgo(name string, n int) (string, string) {
+	versions, err := d.Versions(name)
+	if err != nil || len(versions) == 0 {
+		return "", ""
+	}
+
+	version := versions[0]
+	if len(versions) >= n {
+		version = versions[len(versions)-n]
+	}
+
+	data, _ := d.fs.ReadFile(filepath.Join(d.dataDir, version.File))
+	return version.File, string(data)
+}
+
+// Snapshot 只保存当前状态，不执行差异对比
+func (d *Differ) Snapshot(name string, target string) Diff {
+	return Diff{differ: d, name: name, target: target, changed: true}
+}
+
+// DiffSaved 对比 name 最后保存的两个版本，用于在采集与对比分离时单独执行对比
+func (d *Differ) DiffSaved(name string) (string, error) {
+	versions, err := d.Versions(name)
+	if err != nil {
+		return "", err
+	}
+
+	if len(versions) < 2 {
+		return "", fmt.Errorf("at least 2 saved versions required for %s, got %d", name, len(versions))
+	}
+
+	return d.DiffVersions(versions[len(versions)-2], versions[len(versions)-1])
+}
+
+// DiffVersions 对比两个已保存的版本
+func (d *Differ) DiffVersions(from, to Version) (string, error) {
+	original, err := d.fs.ReadFile(filepath.Join(d.dataDir, from.File))
+	if err != nil {
+		return "", err
+	}
+
+	target, err := d.fs.ReadFile(filepath.Join(d.dataDir, to.File))
+	if err != nil {
+		return "", err
+	}
+
+	return d.diff(from.File, string(original), to.File, string(target)), nil
+}
+
+// DiffText 对比任意两个文档，遵循 Reverse 设置的方向
+func (d *Differ) DiffText(s1name, s1, s2name, s2 string) string {
+	return d.diff(s1name, s1, s2name, s2)
+}
+
+func (d *Differ) diff(s1name, s1, s2name, s2 string) string {
+	if d.semanticJSON != nil {
+		s1, s2 = d.excludeVolatile(s1), d.excludeVolatile(d.tolerate(s1, s2))
+		if d.reverse {
+			s1name, s1, s2name, s2 = s2name, s2, s1name, s1
+		}
+
+		// 任意一个版本不是合法的 JSON 时（如第一次运行），退回到文本对比
+		if report, ok := d.semanticJSON.Diff(s1name, s1, s2name, s2); ok {
+			return report
+		}
+
+		return d.differ.Diff(s1name, s1, s2name, s2)
+	}
+
+	s1, s2 = d.normalize(s1), d.normalize(d.tolerate(s1, s2))
+	if d.reverse {
+		return d.differ.Diff(s2name, s2, s1name, s1)
+	}
+
+	return d.differ.Diff(s1name, s1, s2name, s2)
+}
+
+// Diff 差异对比结果对象
+type Diff struct {
+	differ   *Differ
+	name     string
+	original string
+	target   string
+	diff     string
+	changed  bool
+}
+
+// String 返回差异对比结果
+func (d Diff) String() string {
+	return d.diff
+}
+
+// Original 返回最后一次保存的状态
+func (d Diff) Original() string {
+	return d.original
+}
+
+// Target 返回当前状态
+func (d Diff) Target() string {
+	return d.target
+}
+
+// Changed 返回当前状态与最后一次保存的版本相比是否发生了变化
+func (d Diff) Changed() bool {
+	return d.changed
+}
+
+// Save 保存最后一次状态，新的状态与已经确认的状态不一致时清除确认信息
+func (d Diff) Save() error {
+	fs, dataDir := d.differ.fs, d.differ.dataDir
+
+	targetName := d.differ.nextVersionFile(d.name)
+	if d.diff != "" {
+		_ = fs.WriteFile(filepath.Join(dataDir, targetName+".diff"), []byte(d.diff))
+	}
+	if d.differ.message != "" {
+		_ = fs.WriteFile(filepath.Join(dataDir, targetName+".msg"), []byte(d.differ.message))
+	}
+	_ = fs.WriteFile(filepath.Join(dataDir, targetName+".run"), []byte(runID))
+	if d.differ.storeHash {
+		_ = fs.WriteFile(filepath.Join(dataDir, targetName+".sha256"), []byte(d.Hash()))
+	}
+	if added, removed := diffLineCounts(d.diff); d.differ.interestingThreshold > 0 && uint(added+removed) >= d.differ.interestingThreshold {
+		_ = fs.WriteFile(filepath.Join(dataDir, targetName+".interesting"), []byte(strconv.Itoa(added+removed)))
+	}
+	_ = fs.WriteFile(filepath.Join(dataDir, targetName), []byte(d.target))
+	d.differ.clearStaleAck(d.name, d.target)
+
+	return fs.WriteFile(filepath.Join(dataDir, d.name+".idx"), []byte(targetName))
+}
+
+// Hash 返回当前状态内容的 sha256
+func (d Diff) Hash() string {
+	return checksum(d.target)
+}
+
+// PrintAndSave 将差异对比信息输出，状态发生变化时保存最后一次状态
+func (d Diff) PrintAndSave(out io.Writer) error {
+	if d.diff != "" {
+		_, _ = io.WriteString(out, d.String())
+	}
+
+	if !d.changed {
+		return nil
+	}
+
+	return d.Save()
+}
+
+// Clean 清理保存的状态文件，只保留 keep 个版本
+// 实际上是保留 keep + 1 个版本，始终保留当前版本，设置了 KeepDays 时同时清理超过保留天数的版本
+func (d Diff) Clean(keep uint) error {
+	versions, err := d.differ.Versions(d.name)
+	if err != nil {
+		return err
+	}
+
+	expired := 0
+	if len(versions) > int(keep)+1 {
+		expired = len(versions) - int(keep) - 1
+	}
+
+	// 按照数量清理时，最新的 interestingCap 个重要版本不会被清理
+	protected := make(map[int]bool)
+	for i := len(versions) - 1; i >= 0 && len(protected) < int(d.differ.interestingCap); i-- {
+		if versions[i].Interesting {
+			protected[i] = true
+		}
+	}
+
+	// 按照时间清理时，超过保留天数的版本无论数量多少、是否为重要版本都会被清理，但始终保留最新的版本
+	outdated := 0
+	if d.differ.keepDays > 0 {
+		deadline := d.differ.clock.Now().AddDate(0, 0, -int(d.differ.keepDays))
+		for outdated < len(versions)-1 && versions[outdated].Time().Before(deadline) {
+			outdated++
+		}
+	}
+
+	for i, version := range versions {
+		if i < outdated || (i < expired && !protected[i]) {
+			d.differ.deleteVersion(version)
+		}
+	}
+
+	return nil
+}
+
+// Version 一个已保存的状态版本
+type Version struct {
+	// File 状态文件名，如 mongodb.20201116100000.stat
+	File string
+	// Timestamp 版本时间戳，如 20201116100000
+	Timestamp string
+	// Seq 版本序号，文件名格式中不包含 {seq} 时为 0
+	Seq int
+	// Message 保存该版本时附加的说明信息
+	Message string
+	// RunID 保存该版本的运行标识，之前的版本没有运行标识时为空
+	RunID string
+	// Hash 状态文件内容的 sha256，保存时没有启用 StoreHash 时为空
+	Hash string
+	// Interesting 是否为重要版本，即保存时变化的行数达到了 KeepInteresting 设置的阈值
+	Interesting bool
+}
+
+// Time 返回版本的保存时间
+func (v Version) Time() time.Time {
+	t, _ := time.ParseInLocation("20060102150405", v.Timestamp, time.Local)
+	return t
+}
+
+// Versions 返回 name 对应的所有已保存版本，按照时间从旧到新排序
+func (d *Differ) Versions(name string) ([]Version, error) {
+	files, err := d.fs.ListFiles(d.dataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	fileMatchRegexp := d.versionFileRegexp(name)
+	timestampIndex, seqIndex := -1, -1
+	for i, subexp := range fileMatchRegexp.SubexpNames() {
+		switch subexp {
+		case "timestamp":
+			timestampIndex = i
+		case "seq":
+			seqIndex = i
+		}
+	}
+
+	versions := make([]Version, 0)
+	for _, f := range files {
+		matches := fileMatchRegexp.FindStringSubmatch(f)
+		if matches == nil {
+			continue
+		}
+
+		version := Version{File: f, Timestamp: matches[timestampIndex]}
+		if seqIndex >= 0 {
+			version.Seq, _ = strconv.Atoi(matches[seqIndex])
+		}
+
+		if msg, err := d.fs.ReadFile(filepath.Join(d.dataDir, f+".msg")); err == nil {
+			version.Message = string(msg)
+		}
+		if id, err := d.fs.ReadFile(filepath.Join(d.dataDir, f+".run")); err == nil {
+			version.RunID = string(id)
+		}
+		if hash, err := d.fs.ReadFile(filepath.Join(d.dataDir, f+".sha256")); err == nil {
+			version.Hash = string(hash)
+		}
+		version.Interesting = d.fs.Exist(filepath.Join(d.dataDir, f+".interesting"))
+
+		versions = append(versions, version)
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		if versions[i].Timestamp != versions[j].Timestamp {
+			return versions[i].Timestamp < versions[j].Timestamp
+		}
+
+		return versions[i].Seq < versions[j].Seq
+	})
+
+	return versions, nil
+}
+
+func (d *Differ) deleteVersion(version Version) {
+	targetFile := filepath.Join(d.dataDir, version.File)
+	_ = d.fs.Delete(targetFile)
+	_ = d.fs.Delete(targetFile + ".diff")
+	_ = d.fs.Delete(targetFile + ".msg")
+	_ = d.fs.Delete(targetFile + ".run")
+	_ = d.fs.Delete(targetFile + ".sha256")
+	_ = d.fs.Delete(targetFile + ".interesting")
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSaveWithFixedClock(t *testing.T) {
+	differ := newTestDiffer(t)
+	now := time.Date(2020, 11, 16, 10, 0, 0, 0, time.Local)
+	differ.WithClock(FixedClock(now))
+
+	// 多次使用相同的时间保存，不会覆盖已有的版本
+	for _, state := range []string{"A: 1\n", "A: 2\n", "A: 3\n"} {
+		if err := differ.Snapshot("test", state).Save(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	versions, err := differ.Versions("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"test.20201116100000.stat", "test.20201116100001.stat", "test.20201116100002.stat"}
+	if len(versions) != len(want) {
+		t.Fatalf("expect %d versions, got %d", len(want), len(versions))
+	}
+
+	for i, version := range versions {
+		if version.File != want[i] {
+			t.Errorf("version %d: expect %s, got %s", i, want[i], version.File)
+		}
+	}
+
+	idx, err := differ.fs.ReadFile(filepath.Join(differ.dataDir, "test.idx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(idx) != want[len(want)-1] {
+		t.Errorf("expect .idx to point to the latest version %s, got %s", want[len(want)-1], idx)
+	}
+
+	// 早于最后一个版本的时间顺延到最后一个版本之后，保持版本顺序
+	differ.WithClock(FixedClock(now.Add(-time.Hour)))
+	if err := differ.Snapshot("test", "A: 4\n").PrintAndSave(ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+
+	if idx, _ := differ.fs.ReadFile(filepath.Join(differ.dataDir, "test.idx")); string(idx) != "test.20201116100003.stat" {
+		t.Errorf("expect .idx to point to test.20201116100003.stat, got %s", idx)
+	}
+}
+
+func TestClean(t *testing.T) {
+	day := func(n int) time.Time { return time.Date(2020, 1, n, 0, 0, 0, 0, time.Local) }
+	small := []string{"a\nb\nc\n", "a\nb\nx\n", "a\nb\ny\n", "a\nb\nz\n", "a\nb\nw\n"}
+
+	cases := []struct {
+		name      string
+		states    []string
+		days      []int
+		keep      uint
+		keepDays  uint
+		threshold uint
+		cap       uint
+		now       int
+		// want 清理之后剩下的版本在 states 中的下标
+		want []int
+	}{
+		{name: "keep count", states: small, keep: 2, want: []int{2, 3, 4}},
+		{name: "keep more than saved", states: small, keep: 10, want: []int{0, 1, 2, 3, 4}},
+		{name: "keep zero keeps latest", states: small, keep: 0, want: []int{4}},
+		{
+			name: "keep days", states: small, days: []int{1, 2, 8, 9, 10}, now: 10,
+			keep: 10, keepDays: 3, want: []int{2, 3, 4},
+		},
+		{
+			name: "keep days keeps latest", states: small, days: []int{1, 2, 3, 4, 5}, now: 30,
+			keep: 10, keepDays: 3, want: []int{4},
+		},
+		{
+			name:   "interesting version survives count",
+			states: []string{"a\nb\nc\n", "x\ny\nz\n", "x\ny\n1\n", "x\ny\n2\n", "x\ny\n3\n"},
+			keep:   1, threshold: 4, cap: 1, want: []int{1, 3, 4},
+		},
+		{
+			name:   "interesting cap keeps newest",
+			states: []string{"a\nb\nc\n", "x\ny\nz\n", "a\nb\nc\n", "a\nb\n1\n", "a\nb\n2\n"},
+			keep:   1, threshold: 4, cap: 1, want: []int{2, 3, 4},
+		},
+		{
+			name:   "interesting version within cap",
+			states: []string{"a\nb\nc\n", "x\ny\nz\n", "a\nb\nc\n", "a\nb\n1\n", "a\nb\n2\n"},
+			keep:   1, threshold: 4, cap: 2, want: []int{1, 2, 3, 4},
+		},
+		{
+			name:   "keep days removes interesting versions",
+			states: []string{"a\nb\nc\n", "x\ny\nz\n", "x\ny\n1\n", "x\ny\n2\n", "x\ny\n3\n"},
+			days:   []int{1, 2, 8, 9, 10}, now: 10,
+			keep: 1, keepDays: 3, threshold: 4, cap: 1, want: []int{3, 4},
+		},
+	}
+
+	for _, c := range cases {
+		differ := newTestDiffer(t).KeepDays(c.keepDays).KeepInteresting(c.threshold, c.cap)
+
+		files := make([]string, 0, len(c.states))
+		for i, state := range c.states {
+			saveAt := day(1).Add(time.Duration(i) * time.Minute)
+			if c.days != nil {
+				saveAt = day(c.days[i])
+			}
+
+			differ.WithClock(FixedClock(saveAt))
+			latest := differ.DiffLatest("test", state)
+			if err := latest.PrintAndSave(ioutil.Discard); err != nil {
+				t.Fatal(err)
+			}
+
+			versions, _ := differ.Versions("test")
+			files = append(files, versions[len(versions)-1].File)
+		}
+
+		now := day(1).Add(time.Hour)
+		if c.now > 0 {
+			now = day(c.now)
+		}
+		differ.WithClock(FixedClock(now))
+
+		if err := differ.DiffLatest("test", c.states[len(c.states)-1]).Clean(c.keep); err != nil {
+			t.Fatal(err)
+		}
+
+		versions, err := differ.Versions("test")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got := make([]string, 0, len(versions))
+		for _, version := range versions {
+			got = append(got, version.File)
+		}
+
+		want := make([]string, 0, len(c.want))
+		for _, i := range c.want {
+			want = append(want, files[i])
+		}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: remaining versions %v, want %v", c.name, got, want)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// DiffJSON 结构化的 diff，用于程序处理
+type DiffJSON struct {
+	Name    string       `json:"name"`
+	RunID   string       `json:"run_id"`
+	From    string       `json:"from"`
+	To      string       `json:"to"`
+	Changed bool         `json:"changed"`
+	Added   int          `json:"added"`
+	Removed int          `json:"removed"`
+	Changes []DiffChange `json:"changes"`
+}
+
+// DiffChange diff 中的一行变化，Prefix 为行的类型，如 USER、SETTING
+type DiffChange struct {
+	Op     string `json:"op"`
+	Prefix string `json:"prefix"`
+	Line   string `json:"line"`
+}
+
+// diffJSON 将 unified diff 转换为 JSON，上下文行不包含在结果中，没有变化时 changes 为空数组
+func diffJSON(name, unified string) string {
+	result := DiffJSON{Name: name, RunID: runID, Changes: make([]DiffChange, 0)}
+	for _, line := range strings.Split(unified, "\n") {
+		switch {
+		case strings.HasPrefix(line, "--- "):
+			result.From = strings.TrimPrefix(line, "--- ")
+		case strings.HasPrefix(line, "+++ "):
+			result.To = strings.TrimPrefix(line, "+++ ")
+		case strings.HasPrefix(line, "+"):
+			result.Added++
+			result.Changes = append(result.Changes, DiffChange{Op: "added", Prefix: linePrefix(line[1:]), Line: line[1:]})
+		case strings.HasPrefix(line, "-"):
+			result.Removed++
+			result.Changes = append(result.Changes, DiffChange{Op: "removed", Prefix: linePrefix(line[1:]), Line: line[1:]})
+		}
+	}
+
+	result.Changed = len(result.Changes) > 0
+
+	data, _ := json.Marshal(result)
+	return string(data) + "\n"
+}
+
+// linePrefix 返回行中冒号之前的类型前缀，没有前缀时返回空字符串
+func linePrefix(line string) string {
+	idx := strings.Index(line, ":")
+	if idx <= 0 || strings.ContainsAny(line[:idx], " \t\"") {
+		return ""
+	}
+
+	return line[:idx]
+}
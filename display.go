@@ -0,0 +1,197 @@
+package main
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// displayTransformers 只作用于展示内容（输出、通知、HTTP 接口）的转换，不影响保存的快照与差异计算
+func displayTransformers() []func(string) string {
+	transformers := make([]func(string) string, 0)
+	if len(filterPrefixes) > 0 {
+		transformers = append(transformers, filterLines)
+	}
+
+	if onlyAdded || onlyRemoved {
+		transformers = append(transformers, filterDirection)
+	}
+
+	if hostNames != nil {
+		transformers = append(transformers, annotateHostNames)
+	}
+
+	if hashUsers {
+		transformers = append(transformers, hashUsernames)
+	}
+
+	return transformers
+}
+
+// display 对即将展示的内容执行所有启用的转换
+func display(text string) string {
+	for _, transform := range displayTransformers() {
+		text = transform(text)
+	}
+
+	return text
+}
+
+// displayDiff 对即将输出到终端的 diff 执行展示转换，-output sidebyside 时渲染为左右两列，-output markdown 时渲染为 markdown，-output badge 时渲染为一行状态，-output diff-json 时渲染为结构化的 JSON，
+// 通知与 HTTP 接口仍然使用 unified diff
+func displayDiff(text string) string {
+	text = display(text)
+	switch outputFormat {
+	case "sidebyside":
+		return sideBySide(text, int(sideBySideWidth))
+	case "markdown":
+		return markdownDiff(diffName, text)
+	case "badge":
+		return badge(diffName, text)
+	case "diff-json":
+		return diffJSON(diffName, text)
+	}
+
+	return text
+}
+
+// printAndSave 输出经过展示转换后的 diff，状态发生变化时保存最后一次状态
+func printAndSave(out io.Writer, latest Diff) error {
+	if text := displayDiff(latest.String()); text != "" {
+		_, _ = io.WriteString(out, text)
+	}
+
+	if !latest.Changed() {
+		return nil
+	}
+
+	return latest.Save()
+}
+
+// flushOutput 立即刷新 out 中缓冲的内容，out 带缓冲（如 bufio.Writer、http.ResponseWriter）时，
+// 流式消费方（如 tail -f、HTTP 流式响应）可以在每次运行之后及时看到结果，标准输出本身没有缓冲，不需要刷新
+func flushOutput(out io.Writer) {
+	switch w := out.(type) {
+	case interface{ Flush() error }:
+		if err := w.Flush(); err != nil {
+			log.Printf("flush output failed: %v", err)
+		}
+	case http.Flusher:
+		w.Flush()
+	}
+}
+
+// filterLines 只保留内容以 -filter-prefix 中任意一个前缀开头的行
+func filterLines(text string) string {
+	return filterLinesByPrefix(text, filterPrefixes)
+}
+
+// filterLinesByPrefix 只保留内容以 prefixes 中任意一个前缀开头的行
+//
+// 对于 diff，保留文件头以及匹配的上下文行与变更行，行号信息（@@）在过滤后不再准确，因此被移除；
+// 没有任何匹配的变更行时返回空字符串，此时不会输出 diff，也不会发送通知
+func filterLinesByPrefix(text string, prefixes []string) string {
+	if text == "" {
+		return text
+	}
+
+	if !strings.HasPrefix(text, "--- ") {
+		result := make([]string, 0)
+		for _, line := range strings.SplitAfter(text, "\n") {
+			if hasAnyPrefix(line, prefixes) {
+				result = append(result, line)
+			}
+		}
+
+		return strings.Join(result, "")
+	}
+
+	headers, lines := make([]string, 0), make([]string, 0)
+	changed := false
+	for _, line := range strings.SplitAfter(text, "\n") {
+		switch {
+		case strings.HasPrefix(line, "--- "), strings.HasPrefix(line, "+++ "):
+			headers = append(headers, line)
+		case strings.HasPrefix(line, "@@"), line == "":
+		case hasAnyPrefix(line[1:], prefixes):
+			lines = append(lines, line)
+			if line[0] == '+' || line[0] == '-' {
+				changed = true
+			}
+		}
+	}
+
+	if !changed {
+		return ""
+	}
+
+	return strings.Join(headers, "") + strings.Join(lines, "")
+}
+
+// filterDirection 只保留 diff 中新增（-only-added）或删除（-only-removed）的行，上下文行与行号信息（@@）被移除；
+// 没有该方向的变更行时返回空字符串，快照等非 diff 内容不受影响
+func filterDirection(text string) string {
+	if !strings.HasPrefix(text, "--- ") {
+		return text
+	}
+
+	marker := byte('+')
+	if onlyRemoved {
+		marker = '-'
+	}
+
+	headers, lines := make([]string, 0), make([]string, 0)
+	for _, line := range strings.SplitAfter(text, "\n") {
+		switch {
+		case strings.HasPrefix(line, "--- "), strings.HasPrefix(line, "+++ "):
+			headers = append(headers, line)
+		case line != "" && line[0] == marker:
+			lines = append(lines, line)
+		}
+	}
+
+	if len(lines) == 0 {
+		return ""
+	}
+
+	return strings.Join(headers, "") + strings.Join(lines, "")
+}
+
+func hasAnyPrefix(line string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+var (
+	textUserRegexp   = regexp.MustCompile(`(\buser=)([^,\s]+)`)
+	jsonUserRegexp   = regexp.MustCompile(`("user":\s*")((?:[^"\\]|\\.)*)(")`)
+	jsonUserIDRegexp = regexp.MustCompile(`("id":\s*")([^".]+)\.((?:[^"\\]|\\.)*)(")`)
+)
+
+// hashUsernames 将文本与 JSON 输出中的用户名替换为加盐后的哈希值，同一个用户名始终得到相同的结果
+func hashUsernames(text string) string {
+	text = textUserRegexp.ReplaceAllStringFunc(text, func(s string) string {
+		m := textUserRegexp.FindStringSubmatch(s)
+		return m[1] + hashUsername(m[2])
+	})
+	text = jsonUserRegexp.ReplaceAllStringFunc(text, func(s string) string {
+		m := jsonUserRegexp.FindStringSubmatch(s)
+		return m[1] + hashUsername(m[2]) + m[3]
+	})
+
+	return jsonUserIDRegexp.ReplaceAllStringFunc(text, func(s string) string {
+		m := jsonUserIDRegexp.FindStringSubmatch(s)
+		return m[1] + m[2] + "." + hashUsername(m[3]) + m[4]
+	})
+}
+
+func hashUsername(name string) string {
+	return saltedHash("user-", name)
+}
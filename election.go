@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+)
+
+// ElectionMetricsResp serverStatus.electionMetrics，按照发起选举的原因分别统计
+type ElectionMetricsResp struct {
+	StepUpCmd         ElectionReasonCounter `bson:"stepUpCmd"`
+	PriorityTakeover  ElectionReasonCounter `bson:"priorityTakeover"`
+	CatchUpTakeover   ElectionReasonCounter `bson:"catchUpTakeover"`
+	ElectionTimeout   ElectionReasonCounter `bson:"electionTimeout"`
+	FreezeTimeout     ElectionReasonCounter `bson:"freezeTimeout"`
+	NumCatchUps       int64                 `bson:"numCatchUps"`
+	AverageCatchUpOps float64               `bson:"averageCatchUpOps"`
+}
+
+type ElectionReasonCounter struct {
+	Called     int64 `bson:"called"`
+	Successful int64 `bson:"successful"`
+}
+
+// ElectionMetrics 当前节点发起选举的统计，开启 -election-delta 时为与上一次采集相比的增量
+type ElectionMetrics struct {
+	Called            int64   `json:"called"`
+	Won               int64   `json:"won"`
+	NumCatchUps       int64   `json:"num_catch_ups"`
+	AverageCatchUpOps float64 `json:"average_catch_up_ops"`
+	Delta             bool    `json:"delta"`
+}
+
+// electionMetrics 汇总所有原因发起的选举次数
+func electionMetrics(resp ElectionMetricsResp) ElectionMetrics {
+	metrics := ElectionMetrics{NumCatchUps: resp.NumCatchUps, AverageCatchUpOps: roundSignificant(resp.AverageCatchUpOps)}
+	for _, counter := range []ElectionReasonCounter{resp.StepUpCmd, resp.PriorityTakeover, resp.CatchUpTakeover, resp.ElectionTimeout, resp.FreezeTimeout} {
+		metrics.Called += counter.Called
+		metrics.Won += counter.Successful
+	}
+
+	return metrics
+}
+
+// electionStateFile 保存上一次采集到的选举统计，用于计算增量
+func electionStateFile() string {
+	return filepath.Join(dataDir, diffName+".election.json")
+}
+
+// electionDelta 计算与上一次采集相比的增量，并保存本次采集的结果；没有上一次的结果或计数器被重置（如进程重启）时增量为 0
+func electionDelta(current ElectionMetrics) ElectionMetrics {
+	delta := ElectionMetrics{AverageCatchUpOps: current.AverageCatchUpOps, Delta: true}
+
+	if data, err := ioutil.ReadFile(electionStateFile()); err == nil {
+		var previous ElectionMetrics
+		if err := json.Unmarshal(data, &previous); err == nil && current.Called >= previous.Called && current.Won >= previous.Won && current.NumCatchUps >= previous.NumCatchUps {
+			delta.Called = current.Called - previous.Called
+			delta.Won = current.Won - previous.Won
+			delta.NumCatchUps = current.NumCatchUps - previous.NumCatchUps
+		}
+	}
+
+	data, _ := json.Marshal(current)
+	if err := ioutil.WriteFile(electionStateFile(), data, 0644); err != nil {
+		log.Printf("save election metrics failed: %v", err)
+	}
+
+	return delta
+}
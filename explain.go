@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// explainVersion 输出格式说明的版本，新增或修改输出行时需要同步更新 lineDocs 并递增该版本
+const explainVersion = 34
+
+// lineDoc 一类输出行的说明
+type lineDoc struct {
+	Prefix string
+	Desc   string
+	Fields [][2]string
+}
+
+var lineDocs = []lineDoc{
+	{Prefix: "SUMMARY", Desc: "快照中各类对象的数量统计", Fields: [][2]string{
+		{"databases", "数据库数量"}, {"users", "用户数量"}, {"roles", "自定义角色数量"}, {"members", "副本集成员数量"},
+	}},
+	{Prefix: "DB", Desc: "数据库名称，指定 -collect-db-sizes 时包含以下字段", Fields: [][2]string{
+		{"name", "数据库名称"}, {"sizeOnDiskMB", "占用的磁盘空间，单位 MB，保留两位有效数字"}, {"empty", "数据库是否为空"},
+	}},
+	{Prefix: "USER", Desc: "数据库用户", Fields: [][2]string{
+		{"db", "用户所在的认证数据库"}, {"user", "用户名"},
+	}},
+	{Prefix: "USER_ROLE", Desc: "授予用户的角色", Fields: [][2]string{
+		{"db", "用户所在的认证数据库"}, {"user", "用户名"}, {"role", "角色，格式为 角色所在数据库/角色名"},
+	}},
+	{Prefix: "ROLE", Desc: "自定义角色", Fields: [][2]string{
+		{"db", "角色所在的数据库"}, {"role", "角色名"},
+	}},
+	{Prefix: "ROLE_INHERITS", Desc: "自定义角色继承的角色", Fields: [][2]string{
+		{"db", "角色所在的数据库"}, {"role", "角色名"}, {"inheritsDb", "被继承角色所在的数据库"}, {"inheritsRole", "被继承的角色名"},
+	}},
+	{Prefix: "DBSTATS", Desc: "数据库统计信息（-collect-dbstats）", Fields: [][2]string{
+		{"db", "数据库名称"}, {"collections", "集合数量"}, {"dataSizeMB", "数据量，单位 MB，保留两位有效数字"}, {"indexes", "索引数量"},
+	}},
+	{Prefix: "DBCOLLCOUNT", Desc: "每个数据库中的集合数量（不包含视图），遵循 -exclude-db 与 -database，数量突然减少可能意味着集合被批量删除", Fields: [][2]string{
+		{"db", "数据库名称"}, {"collections", "集合数量"},
+	}},
+	{Prefix: "INDEX", Desc: "集合上的索引定义（-collect-indexes）", Fields: [][2]string{
+		{"db", "数据库名称"}, {"coll", "集合名称"}, {"name", "索引名称"}, {"key", "索引字段，紧凑的 JSON 格式"},
+		{"unique", "是否为唯一索引"}, {"sparse", "是否为稀疏索引"}, {"ttl", "TTL 索引的过期时间（秒），只在 TTL 索引上出现"},
+		{"hidden", "隐藏索引（4.4+），不参与查询计划，只在隐藏索引上出现"}, {"prepareUnique", "正在转换为唯一索引（6.0+），只在设置了该选项的索引上出现"},
+		{"partial", "部分索引的过滤条件，紧凑的 JSON 格式，只在部分索引上出现"},
+	}},
+	{Prefix: "INDEX_SIZE", Desc: "索引占用的存储空间（-collect-index-sizes）", Fields: [][2]string{
+		{"db", "数据库名称"}, {"coll", "集合名称"}, {"name", "索引名称"}, {"sizeMB", "索引大小，单位 MB，保留两位有效数字"},
+	}},
+	{Prefix: "DUP_INDEX", Desc: "同一个集合上索引字段完全相同、只是名称不同的冗余索引（-collect-indexes）", Fields: [][2]string{
+		{"db", "数据库名称"}, {"coll", "集合名称"}, {"names", "冗余的索引名称，使用逗号分隔"},
+	}},
+	{Prefix: "SETTING", Desc: "副本集成员配置（replSetGetConfig）", Fields: [][2]string{
+		{"id", "成员 ID"}, {"host", "成员地址"}, {"vote", "投票数"}, {"arbiterOnly", "是否为仲裁节点"},
+		{"buildIndexes", "是否创建索引"}, {"hidden", "是否为隐藏节点"}, {"priority", "选举优先级"},
+	}},
+	{Prefix: "NO_ELECT_MEMBER", Desc: "priority 为 0、永远不会被选为主节点的成员，不包括仲裁节点、隐藏节点与延迟节点", Fields: [][2]string{
+		{"id", "成员 ID"}, {"host", "成员地址"},
+	}},
+	{Prefix: "MEMBER_TAG", Desc: "副本集成员标签，用于读偏好路由", Fields: [][2]string{
+		{"id", "成员 ID"}, {"key", "标签名"}, {"value", "标签值"},
+	}},
+	{Prefix: "WRITE_CONCERN_MODE", Desc: "副本集配置中自定义的 write concern 模式（settings.getLastErrorModes）", Fields: [][2]string{
+		{"name", "模式名称"}, {"def", "模式定义，按照标签名排序的 JSON，如 {\"dc\":2}"},
+	}},
+	{Prefix: "REPL_SETTING", Desc: "副本集配置 settings 中影响选举与故障切换的配置项，如 electionTimeoutMillis、chainingAllowed，只输出配置中存在的项", Fields: [][2]string{
+		{"key", "配置项名称"}, {"value", "配置项的值"},
+	}},
+	{Prefix: "REPL_STAT", Desc: "副本集成员状态（replSetGetStatus）", Fields: [][2]string{
+		{"id", "成员 ID"}, {"name", "成员地址"}, {"state", "成员状态，如 PRIMARY、SECONDARY"}, {"health", "健康状态，1 为正常"},
+		{"syncSourceHost", "同步源地址"}, {"syncingTo", "同步源地址（旧版本字段）"},
+	}},
+	{Prefix: "ELECTION", Desc: "当前节点发起选举的统计（-collect-elections）", Fields: [][2]string{
+		{"called", "发起选举的次数，包括所有原因"}, {"won", "赢得选举的次数"}, {"numCatchUps", "成为主节点后需要追赶数据的次数"},
+		{"avgCatchUpOps", "平均追赶的操作数，保留两位有效数字"}, {"delta", "为 true 时 called、won、numCatchUps 为与上一次采集相比的增量（-election-delta）"},
+	}},
+	{Prefix: "MEMBER_UNHEALTHY", Desc: "状态不是 PRIMARY、SECONDARY、ARBITER 的副本集成员", Fields: [][2]string{
+		{"name", "成员地址"}, {"state", "成员状态，如 RECOVERING、STARTUP2"},
+	}},
+	{Prefix: "TLSCERT", Desc: "副本集成员提供的 TLS 服务端证书（-collect-tls-certs），未启用 TLS 时不输出", Fields: [][2]string{
+		{"host", "成员地址"}, {"expires", "证书过期日期（UTC）"}, {"daysLeft", "剩余天数，保留两位有效数字，只在接近过期时逐天变化"},
+	}},
+	{Prefix: "HOST", Desc: "成员所在主机信息（-collect-host-info）", Fields: [][2]string{
+		{"host", "成员地址"}, {"numCores", "CPU 核数"}, {"memSizeMB", "内存大小，单位 MB"}, {"cpuArch", "CPU 架构"},
+		{"osType", "操作系统类型"}, {"osName", "操作系统名称"}, {"osVersion", "操作系统版本"},
+	}},
+	{Prefix: "MONGOS", Desc: "分片集群中的 mongos 路由（config.mongos）", Fields: [][2]string{
+		{"host", "mongos 地址"}, {"version", "mongos 版本"},
+	}},
+	{Prefix: "CHUNKS", Desc: "分片集群中每个集合在每个分片上的 chunk 数量（-collect-chunks）", Fields: [][2]string{
+		{"ns", "集合的命名空间，格式为 数据库.集合"}, {"shard", "分片名称"}, {"count", "chunk 数量，数量大幅变化说明发生了 chunk 迁移"},
+	}},
+	{Prefix: "ZONE", Desc: "分片集群中的 zone 范围（config.tags），只在连接到 mongos 时采集", Fields: [][2]string{
+		{"ns", "集合的命名空间，格式为 数据库.集合"}, {"zone", "zone 名称"}, {"min", "范围下界（包含），扩展 JSON 格式"}, {"max", "范围上界（不包含），扩展 JSON 格式"},
+	}},
+	{Prefix: "MIGRATIONS", Desc: "最近一段时间内的 chunk 迁移次数（-collect-migrations），只在连接到 mongos 时采集", Fields: [][2]string{
+		{"last<window>", "时间窗口（-migration-window）内成功的迁移次数，如 last24h"}, {"failed", "时间窗口内失败的迁移次数"},
+	}},
+	{Prefix: "HELLO", Desc: "当前连接节点 hello（isMaster）响应中的拓扑信息", Fields: [][2]string{
+		{"primary", "主节点地址，非副本集时为空"}, {"me", "当前连接的节点地址，非副本集时为空"}, {"setName", "副本集名称"},
+		{"minWireVersion", "支持的最低 wire 协议版本"}, {"maxWireVersion", "支持的最高 wire 协议版本，升级后会发生变化"},
+	}},
+	{Prefix: "TOPOLOGY_VERSION", Desc: "当前连接节点的拓扑版本（-collect-topology-version）", Fields: [][2]string{
+		{"processId", "节点进程标识，进程重启后变化"}, {"counter", "拓扑版本计数，拓扑每次发生变化时递增"},
+	}},
+	{Prefix: "STORAGE", Desc: "存储引擎持久化配置", Fields: [][2]string{
+		{"engine", "存储引擎"}, {"journalEnabled", "是否开启 journal，default 表示未显式配置"},
+		{"directoryPerDB", "是否每个数据库使用单独的目录，default 表示未显式配置"}, {"persistent", "是否持久化存储"}, {"readOnly", "是否只读"},
+	}},
+	{Prefix: "CHECKPOINT", Desc: "checkpoint 与 journal 提交间隔（getParameter），影响崩溃恢复的时间窗口，服务端没有上报时不输出", Fields: [][2]string{
+		{"intervalSecs", "checkpoint 间隔（syncdelay），单位秒"}, {"journalCommitIntervalMs", "journal 提交间隔，单位毫秒，未上报时为空"},
+	}},
+	{Prefix: "CURRENTOP", Desc: "采集时正在执行的操作汇总（-collect-currentop），只反映采集时刻的状态，默认不参与 diff（-diff-currentop）", Fields: [][2]string{
+		{"active", "正在执行的操作数量"}, {"longestSecs", "执行时间最长的操作已经执行的时间，单位秒"},
+	}},
+	{Prefix: "TXN", Desc: "多文档事务相关的服务端参数（getParameter），影响长事务的行为，单机、4.0 之前的版本以及 mongos 不输出", Fields: [][2]string{
+		{"lifetimeLimitSecs", "事务的最长存活时间（transactionLifetimeLimitSeconds），单位秒"}, {"maxLockRequestTimeoutMs", "事务等待锁的最长时间（maxTransactionLockRequestTimeoutMillis），单位毫秒，未上报时为空"},
+	}},
+	{Prefix: "SCRIPTING", Desc: "服务端 JavaScript 脚本配置", Fields: [][2]string{
+		{"javascriptEnabled", "是否允许执行服务端 JavaScript，default 表示未显式配置（默认开启）"},
+	}},
+	{Prefix: "FREEMON", Desc: "云端免费监控（遥测）的状态（getFreeMonitoringStatus），服务端不支持时不输出", Fields: [][2]string{
+		{"state", "状态，包括 enabled、disabled、undecided"},
+	}},
+	{Prefix: "AUTHPROVIDER", Desc: "认证与授权相关配置（getCmdLineOpts），只包含显式配置的项", Fields: [][2]string{
+		{"provider", "认证方式，internal 为内置认证与授权，ldap、kerberos 为外部认证"},
+		{"key", "配置项，嵌套的配置使用 . 连接，如 bind.queryUser"},
+		{"value", "配置值，密码、密钥文件等敏感信息输出为 <redacted:...> 标记，标记中的 HMAC 只用于发现变化"},
+	}},
+	{Prefix: "CMDLINE", Desc: "服务启动时的命令行参数与配置文件（getCmdLineOpts，-collect-cmdline），默认只输出 net、security、replication、sharding、storage.engine，可以通过 -cmdline-include 修改", Fields: [][2]string{
+		{"key", "配置路径，使用 . 连接，如 net.bindIp"},
+		{"value", "配置值，数组使用逗号连接，密码、密钥文件等敏感信息输出为 <redacted:...> 标记"},
+	}},
+	{Prefix: "PARAM", Desc: "服务端参数（getParameter），只包含 -parameters 与 -preset 指定的参数", Fields: [][2]string{
+		{"name", "参数名称"}, {"value", "参数值，文档类型的参数为按照字段名排序的 JSON"},
+	}},
+	{Prefix: "COMMAND_AVAILABLE", Desc: "敏感命令是否可用（-collect-commands、-probe-commands），通过 listCommands 检查，不会实际执行", Fields: [][2]string{
+		{"name", "命令名称"}, {"available", "服务端是否支持该命令，由 false 变为 true 说明开启了新的命令"},
+	}},
+	{Prefix: "CLUSTERPARAM", Desc: "通过 setClusterParameter 设置的集群参数（getClusterParameter），只包含 changeStreamOptions、defaultMaxTimeMS 等部分参数", Fields: [][2]string{
+		{"name", "参数名称"}, {"value", "参数值，紧凑的 JSON 格式"},
+	}},
+	{Prefix: "CHANGESTREAM", Desc: "影响 change stream 消费方的配置（集群参数 changeStreamOptions），6.0 之前的版本不输出", Fields: [][2]string{
+		{"preImageRetention", "pre-images 的保留时间（秒），为 off 时随 oplog 过期，变小可能导致下游无法获取 pre-image"},
+	}},
+	{Prefix: "OPLOG_SIZE", Desc: "oplog 配置的最大容量", Fields: [][2]string{
+		{"maxMB", "最大容量，单位 MB"},
+	}},
+	{Prefix: "AGG[label]", Desc: "-collectors-file 中声明的聚合采集器的结果，每个结果文档一行，内容为字段按照名称排序的紧凑扩展 JSON，按照内容排序；超过 limit 的结果被丢弃，并输出一行 truncated, limit=N"},
+	{Prefix: "(custom)", Desc: "-collectors-file 中声明的自定义采集器输出的行，前缀与格式由声明中的 template 决定"},
+	{Prefix: "ERRORS", Desc: "采集失败的采集器数量", Fields: [][2]string{
+		{"count", "失败的采集器数量"},
+	}},
+	{Prefix: "ERROR", Desc: "采集器失败信息", Fields: [][2]string{
+		{"collector", "采集器名称"}, {"error", "错误信息"},
+	}},
+}
+
+// explain 输出每一类输出行的含义及其字段说明
+func explain(out io.Writer) {
+	_, _ = fmt.Fprintf(out, "mongo-diff 输出格式说明 (v%d)\n", explainVersion)
+	for _, doc := range lineDocs {
+		_, _ = fmt.Fprintf(out, "\n%s: %s\n", doc.Prefix, doc.Desc)
+		for _, field := range doc.Fields {
+			_, _ = fmt.Fprintf(out, "    %-16s %s\n", field[0], field[1])
+		}
+	}
+}
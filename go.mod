@@ -3,6 +3,6 @@ module github.com/mylxsw/mongo-diff
 go 1.14
 
 require (
-	github.com/mylxsw/go-utils v0.0.0-20201116035722-441d165b1324 // indirect
+	github.com/mylxsw/go-utils v0.0.0-20201116035722-441d165b1324
 	go.mongodb.org/mongo-driver v1.4.3
 )
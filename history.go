@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// printHistory 输出 name 对应的所有历史版本，运行标识只输出前 8 位，与日志中的前缀一致，
+// 保存时计算了内容的 sha256（-snapshot-hash）时输出其前 12 位，没有时输出 -
+func printHistory(out io.Writer, differ *Differ, name string) error {
+	versions, err := differ.Versions(name)
+	if err != nil {
+		return err
+	}
+
+	for _, version := range versions {
+		run := "-"
+		if len(version.RunID) >= 8 {
+			run = version.RunID[:8]
+		}
+
+		hash := "-"
+		if len(version.Hash) >= 12 {
+			hash = version.Hash[:12]
+		}
+
+		if version.Message == "" {
+			_, _ = fmt.Fprintf(out, "%s  %s  %s  %s\n", version.Timestamp, version.File, run, hash)
+			continue
+		}
+
+		_, _ = fmt.Fprintf(out, "%s  %s  %s  %s  %s\n", version.Timestamp, version.File, run, hash, version.Message)
+	}
+
+	return nil
+}
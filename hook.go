@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// runPostHook 执行 -post-hook 指定的命令，diff 通过标准输入传递，变化状态与 diff 名称通过环境变量传递
+//
+//	MONGODIFF_CHANGED  状态是否发生了变化，true 或 false
+//	MONGODIFF_NAME     diff 名称
+//	MONGODIFF_RUN_ID   本次运行的唯一标识
+//
+// 命令的退出状态与输出只记录到日志中，执行失败不影响本次运行的结果
+func runPostHook(changed bool, diffText string) {
+	if postHook == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), postHookTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", postHook)
+	cmd.Env = append(os.Environ(), "MONGODIFF_CHANGED="+strconv.FormatBool(changed), "MONGODIFF_NAME="+diffName, "MONGODIFF_RUN_ID="+runID)
+	cmd.Stdin = strings.NewReader(diffText)
+
+	output, err := cmd.CombinedOutput()
+	if out := string(bytes.TrimSpace(output)); out != "" {
+		log.Printf("post hook output: %s", out)
+	}
+
+	if err != nil {
+		log.Printf("post hook failed: %v", err)
+		return
+	}
+
+	log.Printf("post hook finished with exit status 0")
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+type HostInfoResp struct {
+	System HostInfoSystem `bson:"system"`
+	OS     HostInfoOS     `bson:"os"`
+}
+
+type HostInfoSystem struct {
+	Hostname  string `bson:"hostname"`
+	NumCores  int    `bson:"numCores"`
+	MemSizeMB int    `bson:"memSizeMB"`
+	CPUArch   string `bson:"cpuArch"`
+}
+
+type HostInfoOS struct {
+	Type    string `bson:"type"`
+	Name    string `bson:"name"`
+	Version string `bson:"version"`
+}
+
+// HostInfo 副本集成员所在主机的硬件与操作系统信息
+type HostInfo struct {
+	Host      string `json:"host"`
+	NumCores  int    `json:"num_cores"`
+	MemSizeMB int    `json:"mem_size_mb"`
+	CPUArch   string `json:"cpu_arch"`
+	OSType    string `json:"os_type"`
+	OSName    string `json:"os_name"`
+	OSVersion string `json:"os_version"`
+}
+
+func (mm *MongoManager) HostInfo(ctx context.Context) (HostInfoResp, error) {
+	var resp HostInfoResp
+	if err := mm.conn.Database("admin").RunCommand(ctx, bson.M{"hostInfo": 1}).Decode(&resp); err != nil {
+		return HostInfoResp{}, err
+	}
+
+	return resp, nil
+}
+
+// memberHostInfos 直连每一个副本集成员执行 hostInfo 命令，无法获取的成员只记录日志并跳过
+func memberHostInfos(ctx context.Context, mongoURI string, members []ReplSetMemberConfig) ([]HostInfo, error) {
+	hosts := make([]HostInfo, 0, len(members))
+	for _, member := range members {
+		info, err := memberHostInfo(ctx, mongoURI, member.Host)
+		if err != nil {
+			log.Printf("collect host info for %s failed: %v", member.Host, err)
+			continue
+		}
+
+		hosts = append(hosts, info)
+	}
+
+	return hosts, nil
+}
+
+func memberHostInfo(ctx context.Context, mongoURI string, host string) (HostInfo, error) {
+	clientOption, err := newClientOptions(mongoURI)
+	if err != nil {
+		return HostInfo{}, err
+	}
+
+	clientOption.ReplicaSet = nil
+	clientOption.SetHosts([]string{host}).SetDirect(true).SetReadPreference(readpref.Nearest())
+
+	connect, err := mongo.Connect(ctx, clientOption)
+	if err != nil {
+		return HostInfo{}, err
+	}
+	defer connect.Disconnect(context.TODO())
+
+	resp, err := NewMongoManager(connect).HostInfo(ctx)
+	if err != nil {
+		return HostInfo{}, err
+	}
+
+	return HostInfo{
+		Host:      host,
+		NumCores:  resp.System.NumCores,
+		MemSizeMB: resp.System.MemSizeMB,
+		CPUArch:   resp.System.CPUArch,
+		OSType:    resp.OS.Type,
+		OSName:    resp.OS.Name,
+		OSVersion: resp.OS.Version,
+	}, nil
+}
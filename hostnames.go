@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// HostNames 成员地址（host:port）与易读名称的映射（-host-names-file），只用于展示，保存的快照仍然为原始地址
+//
+//	{
+//	  "db1.example.com:27017": "dc1-primary",
+//	  "db2.example.com:27017": "dc2-analytics"
+//	}
+type HostNames struct {
+	names  map[string]string
+	regexp *regexp.Regexp
+}
+
+// hostNames 从 -host-names-file 加载的映射，为 nil 时不执行替换
+var hostNames *HostNames
+
+// loadHostNames 读取并校验成员名称映射文件
+func loadHostNames(filename string) (*HostNames, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("read host names failed: %w", err)
+	}
+
+	var names map[string]string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return nil, fmt.Errorf("invalid host names: %w", err)
+	}
+
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no host found in host names %s", filename)
+	}
+
+	hosts := make([]string, 0, len(names))
+	for host, name := range names {
+		if strings.TrimSpace(host) == "" || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("host and name must not be empty in host names %s", filename)
+		}
+
+		hosts = append(hosts, regexp.QuoteMeta(host))
+	}
+
+	// 较长的地址优先匹配，避免 db1:27017 匹配到 db1:270170 的一部分
+	sort.Slice(hosts, func(i, j int) bool { return len(hosts[i]) > len(hosts[j]) })
+
+	return &HostNames{
+		names:  names,
+		regexp: regexp.MustCompile(strings.Join(hosts, "|")),
+	}, nil
+}
+
+// Annotate 将文本中的成员地址替换为 名称(地址) 的形式，没有映射的地址保持不变
+func (h *HostNames) Annotate(text string) string {
+	var sb strings.Builder
+	last := 0
+	for _, loc := range h.regexp.FindAllStringIndex(text, -1) {
+		// 只替换完整的地址，前后为地址中可能出现的字符时说明只匹配到了其它地址的一部分
+		if (loc[0] > 0 && isHostChar(text[loc[0]-1])) || (loc[1] < len(text) && isHostChar(text[loc[1]])) {
+			continue
+		}
+
+		host := text[loc[0]:loc[1]]
+		sb.WriteString(text[last:loc[0]])
+		sb.WriteString(h.names[host] + "(" + host + ")")
+		last = loc[1]
+	}
+	sb.WriteString(text[last:])
+
+	return sb.String()
+}
+
+func isHostChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte(".:_-", c) >= 0
+}
+
+// annotateHostNames 展示转换，使用 -host-names-file 中的名称标注成员地址
+func annotateHostNames(text string) string {
+	return hostNames.Annotate(text)
+}
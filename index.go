@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type IndexSpec struct {
+	Name                    string `bson:"name"`
+	Key                     bson.D `bson:"key"`
+	Unique                  bool   `bson:"unique"`
+	Sparse                  bool   `bson:"sparse"`
+	ExpireAfterSeconds      *int64 `bson:"expireAfterSeconds"`
+	Hidden                  bool   `bson:"hidden"`
+	PrepareUnique           bool   `bson:"prepareUnique"`
+	PartialFilterExpression bson.D `bson:"partialFilterExpression"`
+}
+
+// Index 集合上的索引定义
+type Index struct {
+	DB     string `json:"db"`
+	Coll   string `json:"coll"`
+	Name   string `json:"name"`
+	Key    string `json:"key"`
+	Unique bool   `json:"unique"`
+	Sparse bool   `json:"sparse"`
+	TTL    *int64 `json:"ttl,omitempty"`
+	// Hidden 是否为隐藏索引（4.4+），隐藏的索引不参与查询计划
+	Hidden bool `json:"hidden,omitempty"`
+	// PrepareUnique 是否正在转换为唯一索引（6.0+），开启后拒绝插入重复的值
+	PrepareUnique bool `json:"prepare_unique,omitempty"`
+	// Partial 部分索引的过滤条件，紧凑的 JSON 格式
+	Partial string `json:"partial,omitempty"`
+}
+
+// DBCollCount 数据库中的集合数量（不包含视图），数量突然减少可能意味着集合被批量删除
+type DBCollCount struct {
+	DB          string `json:"db"`
+	Collections int    `json:"collections"`
+}
+
+// IndexSize 索引占用的存储空间，保留两位有效数字
+type IndexSize struct {
+	DB     string  `json:"db"`
+	Coll   string  `json:"coll"`
+	Name   string  `json:"name"`
+	SizeMB float64 `json:"size_mb"`
+}
+
+// DupIndex 同一个集合上索引字段完全相同的多个索引
+type DupIndex struct {
+	DB    string   `json:"db"`
+	Coll  string   `json:"coll"`
+	Names []string `json:"names"`
+}
+
+type IndexSizesResp struct {
+	IndexSizes map[string]float64 `bson:"indexSizes"`
+}
+
+// CollectionNames 返回数据库中所有的集合名称（不包含视图），按名称排序
+func (mm *MongoManager) CollectionNames(ctx context.Context, db string) ([]string, error) {
+	names, err := mm.conn.Database(db).ListCollectionNames(ctx, bson.M{"type": "collection"})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+// Indexes 返回集合上的所有索引，按名称排序
+func (mm *MongoManager) Indexes(ctx context.Context, db, coll string) ([]IndexSpec, error) {
+	cursor, err := mm.conn.Database(db).Collection(coll).Indexes().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var specs []IndexSpec
+	if err := cursor.All(ctx, &specs); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(specs, func(i, j int) bool { return specs[i].Name < specs[j].Name })
+	return specs, nil
+}
+
+// IndexSizes 返回集合上每个索引占用的存储空间（字节）
+func (mm *MongoManager) IndexSizes(ctx context.Context, db, coll string) (map[string]float64, error) {
+	var resp IndexSizesResp
+	if err := mm.conn.Database(db).RunCommand(ctx, bson.D{{Key: "collStats", Value: coll}}).Decode(&resp); err != nil {
+		return nil, err
+	}
+
+	return resp.IndexSizes, nil
+}
+
+// formatIndexKey 将索引的 key 格式化为紧凑的 JSON，保持字段顺序
+func formatIndexKey(key bson.D) string {
+	data, err := bson.MarshalExtJSON(key, false, false)
+	if err != nil {
+		return ""
+	}
+
+	return string(data)
+}
+
+// formatIndexPartial 将部分索引的过滤条件格式化为紧凑的 JSON，不是部分索引时返回空
+func formatIndexPartial(filter bson.D) string {
+	if len(filter) == 0 {
+		return ""
+	}
+
+	return formatIndexKey(filter)
+}
+
+// duplicateIndexes 查找集合上索引字段（包括字段顺序与方向）完全相同的索引，不考虑索引名称与其它选项
+func duplicateIndexes(db, coll string, specs []IndexSpec) []DupIndex {
+	keys := make([]string, 0)
+	names := make(map[string][]string)
+	for _, spec := range specs {
+		key := formatIndexKey(spec.Key)
+		if _, ok := names[key]; !ok {
+			keys = append(keys, key)
+		}
+
+		names[key] = append(names[key], spec.Name)
+	}
+
+	dups := make([]DupIndex, 0)
+	for _, key := range keys {
+		if len(names[key]) > 1 {
+			dups = append(dups, DupIndex{DB: db, Coll: coll, Names: names[key]})
+		}
+	}
+
+	return dups
+}
+
+// collectCollCounts 采集所有数据库（排除 -exclude-db 匹配的数据库）中的集合数量，每个数据库只执行一次 listCollections（nameOnly）
+func collectCollCounts(ctx context.Context, mm *MongoManager, snapshot *Snapshot) error {
+	for _, db := range filterDatabases(snapshot.Databases) {
+		colls, err := mm.CollectionNames(ctx, db)
+		if err != nil {
+			return err
+		}
+
+		snapshot.CollCounts = append(snapshot.CollCounts, DBCollCount{DB: db, Collections: len(colls)})
+	}
+
+	return nil
+}
+
+// collectIndexes 采集所有数据库（排除 -exclude-db 匹配的数据库）中集合的索引，withSizes 为 true 时同时采集索引大小
+func collectIndexes(ctx context.Context, mm *MongoManager, snapshot *Snapshot, withSizes bool) error {
+	for _, db := range filterDatabases(snapshot.Databases) {
+		colls, err := mm.CollectionNames(ctx, db)
+		if err != nil {
+			return err
+		}
+
+		for _, coll := range colls {
+			specs, err := mm.Indexes(ctx, db, coll)
+			if err != nil {
+				return err
+			}
+
+			for _, spec := range specs {
+				snapshot.Indexes = append(snapshot.Indexes, Index{
+					DB:     db,
+					Coll:   coll,
+					Name:   spec.Name,
+					Key:    formatIndexKey(spec.Key),
+					Unique: spec.Unique,
+					Sparse: spec.Sparse,
+					TTL:    spec.ExpireAfterSeconds,
+					// 旧版本的服务端不返回这些选项，与未设置时相同
+					Hidden:        spec.Hidden,
+					PrepareUnique: spec.PrepareUnique,
+					Partial:       formatIndexPartial(spec.PartialFilterExpression),
+				})
+			}
+
+			snapshot.DupIndexes = append(snapshot.DupIndexes, duplicateIndexes(db, coll, specs)...)
+
+			if !withSizes {
+				continue
+			}
+
+			sizes, err := mm.IndexSizes(ctx, db, coll)
+			if err != nil {
+				return err
+			}
+
+			for _, spec := range specs {
+				if size, ok := sizes[spec.Name]; ok {
+					snapshot.IndexSizes = append(snapshot.IndexSizes, IndexSize{DB: db, Coll: coll, Name: spec.Name, SizeMB: roundSizeMB(size)})
+				}
+			}
+		}
+	}
+
+	return nil
+}
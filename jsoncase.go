@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// supportedJSONCases -json-case 支持的字段命名方式
+var supportedJSONCases = map[string]bool{"snake": true, "camel": true}
+
+var (
+	snapshotFieldNamesOnce sync.Once
+	snapshotFieldNames     map[string]bool
+)
+
+// validateJSONCase 校验 -json-case 参数
+func validateJSONCase(jsonCase string) error {
+	if !supportedJSONCases[jsonCase] {
+		return fmt.Errorf("invalid -json-case value %q: must be snake or camel", jsonCase)
+	}
+
+	return nil
+}
+
+// snakeToCamel 将 snake_case 形式的名称转换为 camelCase，如 size_on_disk_mb 转换为 sizeOnDiskMb
+func snakeToCamel(name string) string {
+	parts := strings.Split(name, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+
+	return strings.Join(parts, "")
+}
+
+// collectJSONFieldNames 收集 t 以及其中嵌套的结构体中包含下划线的 json 字段名称
+func collectJSONFieldNames(t reflect.Type, names map[string]bool, visited map[reflect.Type]bool) {
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		collectJSONFieldNames(t.Elem(), names, visited)
+		return
+	case reflect.Struct:
+	default:
+		return
+	}
+
+	if visited[t] {
+		return
+	}
+	visited[t] = true
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if name := strings.Split(field.Tag.Get("json"), ",")[0]; strings.Contains(name, "_") {
+			names[name] = true
+		}
+
+		collectJSONFieldNames(field.Type, names, visited)
+	}
+}
+
+// camelCaseKeys 将 JSON 文档中快照结构体定义的字段名称转换为 camelCase，保持字段顺序与格式不变，
+// 副本集成员标签等来自数据本身的 key 不会被转换
+func camelCaseKeys(data []byte) []byte {
+	snapshotFieldNamesOnce.Do(func() {
+		snapshotFieldNames = make(map[string]bool)
+		collectJSONFieldNames(reflect.TypeOf(ndjsonRecord{}), snapshotFieldNames, make(map[reflect.Type]bool))
+	})
+
+	result := bytes.NewBuffer(make([]byte, 0, len(data)))
+	for i := 0; i < len(data); i++ {
+		if data[i] != '"' {
+			result.WriteByte(data[i])
+			continue
+		}
+
+		// 找到字符串的结束位置，跳过转义字符
+		end := i + 1
+		for ; end < len(data) && data[end] != '"'; end++ {
+			if data[end] == '\\' {
+				end++
+			}
+		}
+
+		if end >= len(data) {
+			result.Write(data[i:])
+			break
+		}
+
+		str := string(data[i+1 : end])
+		next := end + 1
+		for next < len(data) && (data[next] == ' ' || data[next] == '\t' || data[next] == '\n' || data[next] == '\r') {
+			next++
+		}
+
+		// 只有后面紧跟冒号的字符串才是 key
+		if next < len(data) && data[next] == ':' && snapshotFieldNames[str] {
+			str = snakeToCamel(str)
+		}
+
+		result.WriteString(`"` + str + `"`)
+		i = end
+	}
+
+	return result.Bytes()
+}
+
+// applyJSONCase 按照 -json-case 转换 JSON 文档中的字段名称，默认保持 snake_case
+func applyJSONCase(data []byte) []byte {
+	if jsonCase != "camel" {
+		return data
+	}
+
+	return camelCaseKeys(data)
+}
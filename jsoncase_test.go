@@ -0,0 +1,105 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestValidateJSONCase(t *testing.T) {
+	cases := []struct {
+		value   string
+		wantErr bool
+	}{
+		{value: "snake"},
+		{value: "camel"},
+		{value: "", wantErr: true},
+		{value: "Camel", wantErr: true},
+		{value: "kebab", wantErr: true},
+	}
+
+	for _, c := range cases {
+		if err := validateJSONCase(c.value); (err != nil) != c.wantErr {
+			t.Errorf("validateJSONCase(%q) error = %v, wantErr %t", c.value, err, c.wantErr)
+		}
+	}
+}
+
+func TestSnakeToCamel(t *testing.T) {
+	cases := []struct {
+		name string
+		want string
+	}{
+		{name: "size_on_disk_mb", want: "sizeOnDiskMb"},
+		{name: "repl_stats", want: "replStats"},
+		{name: "users", want: "users"},
+		{name: "a__b", want: "aB"},
+		{name: "trailing_", want: "trailing"},
+		{name: "", want: ""},
+	}
+
+	for _, c := range cases {
+		if got := snakeToCamel(c.name); got != c.want {
+			t.Errorf("snakeToCamel(%q) = %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestCamelCaseKeys(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "snapshot field",
+			in:   `{"repl_stats":[],"users":[]}`,
+			want: `{"replStats":[],"users":[]}`,
+		},
+		{
+			name: "indented",
+			in:   "{\n  \"size_on_disk_mb\" : 1\n}",
+			want: "{\n  \"sizeOnDiskMb\" : 1\n}",
+		},
+		{
+			name: "value equal to a field name is kept",
+			in:   `{"name":"repl_stats"}`,
+			want: `{"name":"repl_stats"}`,
+		},
+		{
+			name: "keys from data are kept",
+			in:   `{"tags":{"my_tag":"a_b"}}`,
+			want: `{"tags":{"my_tag":"a_b"}}`,
+		},
+		{
+			name: "escaped quote in value",
+			in:   `{"name":"a\"repl_stats\":","repl_stats":1}`,
+			want: `{"name":"a\"repl_stats\":","replStats":1}`,
+		},
+		{
+			name: "unterminated string",
+			in:   `{"repl_stats`,
+			want: `{"repl_stats`,
+		},
+	}
+
+	for _, c := range cases {
+		if got := string(camelCaseKeys([]byte(c.in))); got != c.want {
+			t.Errorf("%s: camelCaseKeys(%q) = %q, want %q", c.name, c.in, got, c.want)
+		}
+	}
+}
+
+func TestApplyJSONCase(t *testing.T) {
+	defer func(value string) { jsonCase = value }(jsonCase)
+
+	in := []byte(`{"repl_stats":[]}`)
+
+	jsonCase = "snake"
+	if got := string(applyJSONCase(in)); got != `{"repl_stats":[]}` {
+		t.Errorf("snake: applyJSONCase() = %q", got)
+	}
+
+	jsonCase = "camel"
+	if got := string(applyJSONCase(in)); got != `{"replStats":[]}` {
+		t.Errorf("camel: applyJSONCase() = %q", got)
+	}
+}
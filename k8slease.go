@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// k8sServiceAccountDir Pod 中挂载的 ServiceAccount 凭据目录
+const k8sServiceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// k8sMicroTime Kubernetes MicroTime 的序列化格式
+const k8sMicroTime = "2006-01-02T15:04:05.000000Z07:00"
+
+// errNotInCluster 不在 Kubernetes 集群中运行
+var errNotInCluster = errors.New("not running in a kubernetes cluster")
+
+// Lease coordination.k8s.io/v1 Lease 资源，只包含用到的字段
+type Lease struct {
+	APIVersion string        `json:"apiVersion"`
+	Kind       string        `json:"kind"`
+	Metadata   LeaseMetadata `json:"metadata"`
+	Spec       LeaseSpec     `json:"spec"`
+}
+
+type LeaseMetadata struct {
+	Name            string `json:"name"`
+	Namespace       string `json:"namespace,omitempty"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+type LeaseSpec struct {
+	HolderIdentity       *string `json:"holderIdentity,omitempty"`
+	LeaseDurationSeconds *int    `json:"leaseDurationSeconds,omitempty"`
+	AcquireTime          *string `json:"acquireTime,omitempty"`
+	RenewTime            *string `json:"renewTime,omitempty"`
+}
+
+// expired 判断租约是否已经过期或者没有持有者
+func (l Lease) expired(now time.Time) bool {
+	if l.Spec.HolderIdentity == nil || *l.Spec.HolderIdentity == "" || l.Spec.RenewTime == nil || l.Spec.LeaseDurationSeconds == nil {
+		return true
+	}
+
+	renewTime, err := time.Parse(k8sMicroTime, *l.Spec.RenewTime)
+	if err != nil {
+		return true
+	}
+
+	return now.After(renewTime.Add(time.Duration(*l.Spec.LeaseDurationSeconds) * time.Second))
+}
+
+// LeaderElector 基于 Kubernetes Lease 的选主，同一个租约同时只有一个实例可以运行，
+// 直接调用 Kubernetes API，使用 Pod 中挂载的 ServiceAccount 认证，需要对 leases 资源的 get、create、update 权限
+type LeaderElector struct {
+	client    *http.Client
+	server    string
+	token     string
+	namespace string
+	name      string
+	identity  string
+	duration  time.Duration
+
+	lease Lease
+	stop  chan struct{}
+	done  chan struct{}
+	// lost 续约失败、租约可能已经被其它实例获取时关闭
+	lost chan struct{}
+}
+
+// NewLeaderElector 使用集群内配置创建选主对象，不在 Kubernetes 集群中运行时返回 errNotInCluster
+func NewLeaderElector(name string, duration time.Duration) (*LeaderElector, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errNotInCluster
+	}
+
+	token, err := ioutil.ReadFile(k8sServiceAccountDir + "/token")
+	if err != nil {
+		return nil, errNotInCluster
+	}
+
+	namespace, err := ioutil.ReadFile(k8sServiceAccountDir + "/namespace")
+	if err != nil {
+		return nil, fmt.Errorf("read kubernetes namespace failed: %w", err)
+	}
+
+	ca, err := ioutil.ReadFile(k8sServiceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, fmt.Errorf("read kubernetes ca failed: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("no valid certificate found in kubernetes ca")
+	}
+
+	identity, _ := os.Hostname()
+	return &LeaderElector{
+		client:    &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}, Timeout: 10 * time.Second},
+		server:    "https://" + net.JoinHostPort(host, port),
+		token:     strings.TrimSpace(string(token)),
+		namespace: strings.TrimSpace(string(namespace)),
+		name:      name,
+		identity:  identity + "_" + runID,
+		duration:  duration,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+		lost:      make(chan struct{}),
+	}, nil
+}
+
+func (e *LeaderElector) leaseURL(withName bool) string {
+	u := fmt.Sprintf("%s/apis/coordination.k8s.io/v1/namespaces/%s/leases", e.server, e.namespace)
+	if withName {
+		u += "/" + e.name
+	}
+
+	return u
+}
+
+// request 发送请求，返回响应状态码，响应为 2xx 时将结果解码到 out
+func (e *LeaderElector) request(ctx context.Context, method, url string, body interface{}, out interface{}) (int, error) {
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+e.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, nil
+	}
+
+	return resp.StatusCode, json.Unmarshal(respBody, out)
+}
+
+// TryAcquire 尝试获取租约，租约被其它未过期的实例持有时返回 false
+func (e *LeaderElector) TryAcquire(ctx context.Context) (bool, error) {
+	now := time.Now().Format(k8sMicroTime)
+	seconds := int(e.duration.Seconds())
+
+	var current Lease
+	status, err := e.request(ctx, http.MethodGet, e.leaseURL(true), nil, &current)
+	if err != nil {
+		return false, err
+	}
+
+	switch status {
+	case http.StatusNotFound:
+		lease := Lease{
+			APIVersion: "coordination.k8s.io/v1",
+			Kind:       "Lease",
+			Metadata:   LeaseMetadata{Name: e.name, Namespace: e.namespace},
+			Spec:       LeaseSpec{HolderIdentity: &e.identity, LeaseDurationSeconds: &seconds, AcquireTime: &now, RenewTime: &now},
+		}
+
+		status, err = e.request(ctx, http.MethodPost, e.leaseURL(false), lease, &e.lease)
+		if err != nil {
+			return false, err
+		}
+
+		// 409 表示其它实例同时创建了租约
+		if status == http.StatusConflict {
+			return false, nil
+		}
+	case http.StatusOK:
+		if !current.expired(time.Now()) && *current.Spec.HolderIdentity != e.identity {
+			return false, nil
+		}
+
+		current.Spec.HolderIdentity, current.Spec.LeaseDurationSeconds = &e.identity, &seconds
+		current.Spec.AcquireTime, current.Spec.RenewTime = &now, &now
+
+		// 使用 resourceVersion 实现乐观锁，409 表示租约已经被其它实例更新
+		status, err = e.request(ctx, http.MethodPut, e.leaseURL(true), current, &e.lease)
+		if err != nil {
+			return false, err
+		}
+
+		if status == http.StatusConflict {
+			return false, nil
+		}
+	}
+
+	if status < 200 || status >= 300 {
+		return false, fmt.Errorf("acquire lease %s/%s failed: kubernetes api responded with status %d", e.namespace, e.name, status)
+	}
+
+	go e.renew()
+	return true, nil
+}
+
+// renew 在持有租约期间定期续约，用于 -watch、-serve 等长时间运行的模式；
+// 租约已经被其它实例更新或删除（409、404），或者直到租约过期都没有续约成功时，认为已经失去租约，关闭 lost 后退出
+func (e *LeaderElector) renew() {
+	defer close(e.done)
+
+	ticker := time.NewTicker(e.duration / 3)
+	defer ticker.Stop()
+
+	lastRenew := time.Now()
+	for {
+		select {
+		case <-e.stop:
+			return
+		case <-ticker.C:
+			now := time.Now().Format(k8sMicroTime)
+			lease := e.lease
+			lease.Spec.RenewTime = &now
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			status, err := e.request(ctx, http.MethodPut, e.leaseURL(true), lease, &e.lease)
+			cancel()
+
+			if err == nil && status >= 200 && status < 300 {
+				lastRenew = time.Now()
+				continue
+			}
+
+			log.Printf("renew lease %s/%s failed: status=%d, err=%v", e.namespace, e.name, status, err)
+			if status == http.StatusConflict || status == http.StatusNotFound || time.Since(lastRenew) >= e.duration {
+				log.Printf("lease %s/%s lost, stepping down", e.namespace, e.name)
+				close(e.lost)
+				return
+			}
+		}
+	}
+}
+
+// Lost 返回失去租约时关闭的 channel
+func (e *LeaderElector) Lost() <-chan struct{} {
+	return e.lost
+}
+
+// Release 释放租约，下一次运行不需要等待租约过期，只能在 TryAcquire 成功之后调用
+func (e *LeaderElector) Release() {
+	close(e.stop)
+	<-e.done
+
+	// 租约可能已经被其它实例持有，不能释放
+	select {
+	case <-e.lost:
+		return
+	default:
+	}
+
+	empty := ""
+	lease := e.lease
+	lease.Spec.HolderIdentity = &empty
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var released Lease
+	if status, err := e.request(ctx, http.MethodPut, e.leaseURL(true), lease, &released); err != nil || status < 200 || status >= 300 {
+		log.Printf("release lease %s/%s failed: status=%d, err=%v", e.namespace, e.name, status, err)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// errLocked 锁已经被其它进程持有
+var errLocked = errors.New("locked")
+
+// HistoryLock data-dir 中一个名称的历史版本的排它锁，避免多个实例同时写入同一份历史记录
+type HistoryLock struct {
+	f *os.File
+}
+
+// lockHistory 获取 {dataDir}/{name}.lock 上的排它锁，锁被其它实例持有时最多等待 timeout，超时后返回错误
+func lockHistory(dataDir, name string, timeout time.Duration) (*HistoryLock, error) {
+	lockFile := filepath.Join(dataDir, name+".lock")
+	f, err := os.OpenFile(lockFile, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open lock file failed: %w", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		err := tryLockFile(f)
+		if err == nil {
+			break
+		}
+
+		if !errors.Is(err, errLocked) {
+			_ = f.Close()
+			return nil, fmt.Errorf("lock %s failed: %w", lockFile, err)
+		}
+
+		if !time.Now().Before(deadline) {
+			_ = f.Close()
+			return nil, fmt.Errorf("another mongo-diff instance is already running for %s (lock file %s), use -lock-timeout to wait for it", name, lockFile)
+		}
+
+		time.Sleep(200 * time.Millisecond)
+	}
+
+	return &HistoryLock{f: f}, nil
+}
+
+// Unlock 释放锁，进程退出时锁也会被自动释放
+func (l *HistoryLock) Unlock() {
+	_ = unlockFile(l.f)
+	_ = l.f.Close()
+}
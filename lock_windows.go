@@ -0,0 +1,12 @@
+package main
+
+import "os"
+
+// Windows 上不支持 flock，不对历史记录加锁
+func tryLockFile(f *os.File) error {
+	return nil
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
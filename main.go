@@ -3,113 +3,943 @@ package main
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/url"
 	"os"
+	"os/signal"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/template"
 	"time"
 
-	"github.com/mylxsw/go-utils/diff"
 	"github.com/mylxsw/go-utils/file"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 var mongoURI, diffName string
 var dataDir string
-var contextLine, keepVersion uint
-var noDiff bool
+var contextLine, keepVersion, keepDays uint
+var interestingThreshold, keepInteresting uint
+var noDiff, baseline, reverseDiff bool
+var requireBaseline bool
+var ignoreWhitespace bool
+var semanticJSON, jsonIgnoreArrayOrder bool
+var numericToleranceExpr, numericToleranceKeys string
+var collectOnly, diffSaved bool
+var compareMode bool
+var baselineFile string
+var diffAgainst uint
+var diffCommon uint
+var versionFilenameFormat string
+var hashUsers bool
+var pager string
+var maxValueLength uint
+var hostNamesFile string
+var onlyAdded, onlyRemoved bool
+var hashSalt string
+var filterPrefixExpr string
+var filterPrefixes []string
+var collectDBStats, collectorTiming bool
+var collectIndexesEnabled, collectIndexSizes bool
+var collectTopologyVersion bool
+var collectChunks bool
+var collectCommands bool
+var probeCommandsExpr string
+var collectMigrations bool
+var migrationWindow time.Duration
+var collectDBSizes bool
+var collectElections, electionDeltaEnabled bool
+var cmdLineInclude string
+var collectCmdLine bool
+var parametersExpr, presetExpr string
+var parameterNames []string
+var excludeDB string
+var excludeDBPatterns []string
+var scopeDatabase string
+var memberFilterExpr string
+var memberFilter *MemberFilter
+var collectHostInfo, strictPrivileges bool
+var nowOverride, message string
+var snapshotHash bool
+var showHistory bool
+var interactive bool
+var batchFile string
+var collectorsFile string
+var exportFile, importFile string
+var acknowledge bool
+var notifyWebhooks, notifyProxy, notifyCAFile string
+var notifyRoutesFile string
+var notifyTimeout time.Duration
+var notifyRecovery bool
+var postHook string
+var postHookTimeout time.Duration
+var explainMode, checkMode bool
+var noClean bool
+var logToStdout bool
+var notifiers []Notifier
+var softCollectErr error
+var failOnUnhealthy bool
+var collectTLSCerts bool
+var tlsCertWarnDays uint
+var collectCurrentOp, diffCurrentOp bool
+var currentOpWarnSecs uint
+var serveAddr string
+var outputExpr, outputFormat, selectExpr string
+var outputTargets []OutputTarget
+var indentExpr, jsonIndent string
+var jsonCase string
+var sideBySideWidth uint
+var badgeJSON bool
+var selectPaths []SelectPath
+var textTemplateExpr string
+var textTemplate *template.Template
+var x509CertFile, x509KeyFile, tlsCAFile string
+var serverSelectionTimeout time.Duration
+var serveInterval time.Duration
+var watchMode bool
+var lockTimeout time.Duration
+var k8sLease string
+var k8sLeaseDuration time.Duration
+
+// leaseLost 持有 -k8s-lease 租约时，失去租约后关闭，signalContext 随之结束，未使用租约时为 nil
+var leaseLost <-chan struct{}
+var mongosMaxPingAge time.Duration
+
+// 单次运行模式下的退出状态码，持续运行模式（-watch、-serve）正常退出时始终为 0
+const (
+	// exitNoChange 运行成功，状态没有发生变化
+	exitNoChange = 0
+	// exitError 其它错误，如参数错误、存在不健康的副本集成员（-fail-on-unhealthy）
+	exitError = 1
+	// exitChanged 运行成功，状态发生了变化
+	exitChanged = 2
+	// exitConnectionError 无法连接到 MongoDB
+	exitConnectionError = 3
+	// exitPartialError 部分采集器失败，快照仍然会输出与保存
+	exitPartialError = 4
+)
+
+// exitCode 运行结束后的退出状态码，检测到变化时设置为 exitChanged
+var exitCode = exitNoChange
 
 func main() {
-	flag.StringVar(&mongoURI, "mongo-uri", "mongodb://localhost:27017", "MongoDB URI，参考文档 https://docs.mongodb.com/manual/reference/connection-string/")
+	log.SetPrefix("run=" + runID[:8] + " ")
+	log.SetOutput(redactWriter{w: os.Stderr})
+
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("%v", r)
+
+			var connErr ConnectionError
+			if err, ok := r.(error); ok && errors.As(err, &connErr) {
+				os.Exit(exitConnectionError)
+			}
+
+			os.Exit(exitError)
+		}
+	}()
+
+	run()
+
+	if softCollectErr != nil {
+		log.Printf("collect finished with error: %v", softCollectErr)
+		if isPartialError(softCollectErr) {
+			os.Exit(exitPartialError)
+		}
+
+		os.Exit(exitError)
+	}
+
+	os.Exit(exitCode)
+}
+
+// exitChangedIf 检测到变化时将退出状态码设置为 exitChanged
+func exitChangedIf(changed bool) {
+	if changed {
+		exitCode = exitChanged
+	}
+}
+
+// mustCollect 采集失败时 panic；部分采集器失败、存在不健康成员等不影响采集结果的错误只记录下来，在输出与保存完成之后以非 0 状态码退出
+func mustCollect(err error) {
+	if err == nil {
+		return
+	}
+
+	if isSoftError(err) {
+		softCollectErr = err
+		return
+	}
+
+	panic(err)
+}
+
+func run() {
+	// -selftest 用于 CI 中检查输出是否稳定，不连接 MongoDB，也不出现在帮助信息中
+	if len(os.Args) == 2 && os.Args[1] == "-selftest" {
+		if selftest(os.Stdout) > 0 {
+			exitCode = exitError
+		}
+
+		return
+	}
+
+	flag.StringVar(&mongoURI, "mongo-uri", "mongodb://localhost:27017", "MongoDB URI，参考文档 https://docs.mongodb.com/manual/reference/connection-string/，未指定时优先使用环境变量 MONGODIFF_MONGO_URI")
 	flag.StringVar(&dataDir, "data-dir", "./tmp", "diff 状态数据存储目录")
 	flag.UintVar(&contextLine, "context-line", 2, "diff 上下文信息数量")
+	flag.StringVar(&versionFilenameFormat, "version-filename-format", defaultVersionFilenameFormat, "data-dir 中状态文件的文件名格式，支持 {name}、{timestamp}、{seq}（6 位递增序号）占位符，必须包含 {name} 与 {timestamp}，修改后之前格式的历史版本将不再被识别")
 	flag.UintVar(&keepVersion, "keep-version", 100, "保留多少个版本的历史记录")
+	flag.BoolVar(&noClean, "no-clean", false, "不清理任何历史版本，忽略 -keep-version 与 -keep-days，适用于排查问题期间保留完整历史")
+	flag.BoolVar(&checkMode, "check", false, "只检查连接以及当前用户的权限下每个内置采集器能否成功执行（-collectors-file 中的采集器不会执行，报告为未检查），不输出快照，也不保存任何状态，与 -output json 一起使用时输出 JSON 格式的检查报告，任意采集器失败时以非 0 状态码退出")
+	flag.BoolVar(&explainMode, "explain", false, "输出每一类输出行及其字段的含义说明")
+	flag.UintVar(&interestingThreshold, "interesting-threshold", 0, "变化的行数（新增与删除）达到该值的版本在保存时标记为重要版本，按照 -keep-version 清理时不会被清理，为 0 时不标记")
+	flag.UintVar(&keepInteresting, "keep-interesting", 10, "与 -interesting-threshold 一起使用，按照 -keep-version 清理时最多保留多少个最新的重要版本，超过的重要版本与普通版本一样被清理，不影响 -keep-days")
+	flag.UintVar(&keepDays, "keep-days", 0, "保留多少天内的历史记录，超过该天数的版本无论 -keep-version 是多少都会被清理（始终保留最新版本），为 0 时不按时间清理")
 	flag.BoolVar(&noDiff, "no-diff", false, "只输出基本信息，不执行 diff")
-	flag.StringVar(&diffName, "name", "mongodb", "Diff 名称")
+	flag.BoolVar(&baseline, "baseline", false, "将当前状态保存为基线版本，不输出 diff")
+	flag.BoolVar(&requireBaseline, "require-baseline", false, "data-dir 中没有该名称已保存的版本时报错退出，而不是将所有内容都作为新增输出，用于在流水线中确保已经单独执行过 -baseline")
+	flag.BoolVar(&collectOnly, "collect-only", false, "只采集并保存为新版本，不执行 diff，也不输出任何内容")
+	flag.BoolVar(&compareMode, "compare-json", false, "不连接 MongoDB，离线对比命令行中指定的两个快照文件（text 或 json 格式，自动识别），如 mongo-diff -compare-json old.json new.json")
+	flag.BoolVar(&diffSaved, "diff-saved", false, "不连接 MongoDB，只对比已保存的最后两个版本")
+	flag.StringVar(&baselineFile, "baseline-file", "", "与指定的快照文件（格式与 -output 一致）进行对比，而不是与历史版本对比，不保存当前状态")
+	flag.UintVar(&diffAgainst, "diff-against", 1, "与之前第 N 个版本进行对比，用于发现缓慢累积的变化，默认与最后一次保存的版本对比")
+	flag.BoolVar(&semanticJSON, "semantic-json", false, "与 -output json 一起使用，按照结构对比 JSON 快照，忽略字段顺序，输出基于路径的变更报告，如 users[2].roles added \"admin\"")
+	flag.BoolVar(&jsonIgnoreArrayOrder, "json-ignore-array-order", false, "与 -semantic-json 一起使用，对比时同时忽略数组元素的顺序，数组的变化报告为新增与删除的元素")
+	flag.BoolVar(&ignoreWhitespace, "ignore-whitespace", false, "对比时忽略空白字符的差异（连续的空白字符视为一个空格，忽略行首行尾的空白），保存的快照不受影响")
+	flag.UintVar(&diffCommon, "diff-common", 0, "与最近 N 个版本中共同存在的行进行对比，只报告持续存在的变化，减少反复变化带来的噪音，为 0 时不启用，不能与 -diff-against 同时使用")
+	flag.StringVar(&numericToleranceExpr, "numeric-tolerance", "", "数字字段值的变化在该范围内时不视为变化，可以为绝对值（如 0.5）或百分比（如 5%），用于减少数据量、数量等字段的细微波动带来的噪音，保存的快照不受影响")
+	flag.StringVar(&numericToleranceKeys, "numeric-tolerance-keys", defaultNumericToleranceKeys, "逗号分隔的应用 -numeric-tolerance 的字段名，不区分大小写与下划线，其它数字字段（如 priority、votes）的任何变化都会输出")
+	flag.BoolVar(&reverseDiff, "reverse-diff", false, "反转 diff 方向，将当前状态作为 before、上一个版本作为 after")
+	flag.StringVar(&diffName, "name", "mongodb", "Diff 名称，未指定时根据 -mongo-uri 中的主机名生成，无法识别主机名时为 mongodb")
+	flag.StringVar(&nowOverride, "now", "", "覆盖版本文件名使用的当前时间，RFC3339 格式，如 2020-11-16T10:00:00+08:00，不晚于最后一个版本时顺延到最后一个版本之后一秒，不会覆盖已有的版本")
+	flag.BoolVar(&snapshotHash, "snapshot-hash", false, "保存版本时同时保存内容的 sha256，-history 中输出哈希值，与最后一次保存的版本哈希相同时跳过 diff 计算，单次运行时在日志中输出当前快照的哈希")
+	flag.StringVar(&message, "message", "", "为本次保存的版本附加说明信息，如 \"before maintenance\"，不参与 diff")
+	flag.StringVar(&collectorsFile, "collectors-file", "", "自定义采集器配置文件（JSON 格式），每个采集器在指定数据库上执行一个命令，并使用模板将结果格式化为输出行，或者在指定集合上执行聚合管道并输出 AGG[label] 行，与内置采集器一起运行")
+	flag.StringVar(&batchFile, "batch", "", "批量运行配置文件（JSON 格式），为每个集群启动独立的进程运行并输出各自的报告，其余参数对所有集群生效")
+	flag.BoolVar(&showHistory, "history", false, "列出已保存的历史版本及其说明信息")
+	flag.BoolVar(&interactive, "tui", false, "进入交互式界面浏览已保存的历史版本，选择任意两个版本查看差异，需要在终端中运行")
+	flag.BoolVar(&acknowledge, "ack", false, "确认最后一次保存的状态，当前状态与确认的状态一致时不再发送变化通知，直到状态再次发生变化，可以使用 -message 附加说明")
+	flag.StringVar(&exportFile, "export", "", "将 -name 对应的所有历史版本导出为 JSON 归档文件，- 表示输出到标准输出")
+	flag.StringVar(&importFile, "import", "", "从 -export 导出的归档文件导入历史版本，保留原有的时间戳与说明信息，未指定 -name 时使用归档中的名称")
+	flag.StringVar(&serveAddr, "serve", "", "以 HTTP 服务模式运行，指定监听地址，如 :8080")
+	flag.DurationVar(&serveInterval, "interval", time.Minute, "HTTP 服务模式与 watch 模式下的采集间隔")
+	flag.BoolVar(&watchMode, "watch", false, "持续监听模式，按照 -interval 周期采集，只在状态发生变化时输出 diff")
+	flag.StringVar(&k8sLease, "k8s-lease", "", "在 Kubernetes 中运行时，使用该名称的 Lease 选主，只有获取到租约的实例执行，其它实例直接退出，不在 Kubernetes 中运行时忽略")
+	flag.DurationVar(&k8sLeaseDuration, "k8s-lease-duration", 5*time.Minute, "-k8s-lease 租约的有效期，持有者异常退出时，其它实例需要等待租约过期后才能获取")
+	flag.DurationVar(&lockTimeout, "lock-timeout", 0, "同一个 data-dir 中相同名称的实例正在运行时，等待其结束的最长时间，为 0 时直接退出，避免重叠运行损坏历史记录")
+	flag.StringVar(&outputExpr, "output", "text", "输出格式，支持 text、json、sidebyside（快照与 text 相同，diff 以左右两列的形式输出）、markdown（快照与 text 相同，diff 输出为适用于 GitHub PR 评论的 markdown）、badge（快照与 text 相同，diff 只输出一行状态，如 OK、CHANGED 7）、diff-json（快照与 text 相同，diff 输出为 {op, prefix, line} 组成的 JSON 列表，便于程序处理）、ndjson（单行紧凑 JSON，包含 diff 名称与采集时间，只能与 -no-diff 一起使用或作为附加输出），可以使用 format:dest 的形式同时输出多种格式，如 text:-,json:/tmp/snap.json，第一个格式用于 diff")
+	flag.StringVar(&hostNamesFile, "host-names-file", "", "成员地址与易读名称的映射文件（JSON 格式，如 {\"db1:27017\": \"dc1-primary\"}），在输出与通知中将地址标注为 名称(地址)，保存的快照不受影响")
+	flag.UintVar(&maxValueLength, "max-value-length", 0, "截断快照中超过该长度（字符数）的值，并追加完整内容的 sha256 哈希，截断的部分发生变化时仍然可以发现差异，用于避免超长的配置值占满快照，为 0 时不截断")
+	flag.StringVar(&pager, "pager", "", "标准输出为终端时，通过该分页程序输出 diff（如 less -R），未指定时使用 PAGER 环境变量，非交互运行时（如重定向、定时任务）不生效")
+	flag.BoolVar(&hashUsers, "hash-users", false, "在输出与通知中使用加盐哈希替换用户名，保存的快照中仍然为原始用户名")
+	flag.BoolVar(&onlyAdded, "only-added", false, "只在输出与通知中保留 diff 中新增的行，如新增的用户，保存的快照与 diff 不受影响")
+	flag.BoolVar(&onlyRemoved, "only-removed", false, "只在输出与通知中保留 diff 中删除的行，如删除的集合，保存的快照与 diff 不受影响")
+	flag.StringVar(&filterPrefixExpr, "filter-prefix", "", "只在输出与通知中保留以这些前缀开头的行，多个前缀使用逗号分隔，如 USER:,ROLE:，保存的快照不受影响")
+	flag.StringVar(&hashSalt, "hash-salt", "", "-hash-users 使用的盐值")
+	flag.BoolVar(&badgeJSON, "badge-json", false, "-output badge 时输出 shields.io 兼容的 JSON，可以直接作为 shields.io endpoint 徽章的数据源")
+	flag.UintVar(&sideBySideWidth, "sidebyside-width", 160, "-output sidebyside 时输出的总宽度（字符数），超过列宽的行自动折行")
+	flag.StringVar(&jsonCase, "json-case", "snake", "json、ndjson 输出中字段名称的命名方式，支持 snake（如 size_on_disk_mb）、camel（如 sizeOnDiskMb），-select 中的路径仍然使用 snake 形式")
+	flag.StringVar(&indentExpr, "indent", "2", "JSON 输出的缩进空格数，tab 表示使用制表符")
+	flag.StringVar(&textTemplateExpr, "template", "", "自定义文本输出格式的 Go text/template 模板，以 @ 开头时从文件读取，如 @format.tmpl，为空时使用默认格式")
+	flag.StringVar(&selectExpr, "select", "", "只保留 JSON 输出中选中的字段，使用 JSONPath 风格的表达式，多个表达式使用逗号分隔，如 $.users[*].roles")
+	flag.DurationVar(&mongosMaxPingAge, "mongos-max-ping-age", time.Hour, "忽略最后一次 ping 时间早于该时长的 mongos 记录，为 0 时不过滤")
+	flag.BoolVar(&collectHostInfo, "collect-host-info", false, "采集副本集成员所在主机的硬件与操作系统信息（hostInfo），需要 clusterMonitor 等较高权限")
+	flag.BoolVar(&collectDBSizes, "collect-db-sizes", false, "在 DB 行中输出每个数据库占用的磁盘空间（listDatabases），以及数据库是否为空")
+	flag.BoolVar(&collectDBStats, "collect-dbstats", false, "采集每个数据库的 dbStats 统计信息（集合数、数据量、索引数），数据库较多时开销较大")
+	flag.BoolVar(&collectIndexesEnabled, "collect-indexes", false, "采集所有集合的索引定义，集合较多时开销较大")
+	flag.BoolVar(&collectIndexSizes, "collect-index-sizes", false, "采集所有集合的索引定义以及每个索引的大小（需要额外执行 collStats）")
+	flag.BoolVar(&collectMigrations, "collect-migrations", false, "分片集群中统计最近一段时间内成功与失败的 chunk 迁移次数（config.changelog），只在连接到 mongos 时采集")
+	flag.DurationVar(&migrationWindow, "migration-window", 24*time.Hour, "-collect-migrations 统计 chunk 迁移次数的时间窗口")
+	flag.BoolVar(&collectCommands, "collect-commands", false, "通过 listCommands 检查 eval、getLog 等敏感命令是否可用（不会实际执行这些命令），用于发现新开启的危险命令")
+	flag.StringVar(&probeCommandsExpr, "probe-commands", "", "-collect-commands 检查的命令，多个使用逗号分隔，为空时检查 "+strings.Join(defaultProbeCommands, ","))
+	flag.BoolVar(&collectChunks, "collect-chunks", false, "分片集群中按照集合与分片统计 chunk 数量（聚合 config.chunks），chunk 较多时开销较大")
+	flag.StringVar(&parametersExpr, "parameters", "", "采集这些服务端参数（getParameter），多个使用逗号分隔，如 syncdelay,enableFlowControl")
+	flag.StringVar(&presetExpr, "preset", "", "采集预设的服务端参数集合，支持 capacity（连接池与并发）、security（认证与审计）、durability（持久化与复制），多个使用逗号分隔，可以与 -parameters 同时使用")
+	flag.BoolVar(&collectCmdLine, "collect-cmdline", false, "采集服务启动时的命令行参数与配置文件（getCmdLineOpts），输出的配置路径由 -cmdline-include 指定")
+	flag.StringVar(&cmdLineInclude, "cmdline-include", defaultCmdLineInclude, "开启 -collect-cmdline 时只输出这些配置路径下的启动参数，多个使用逗号分隔，如 net,storage.wiredTiger，为 * 时输出全部")
+	flag.BoolVar(&collectElections, "collect-elections", false, "采集当前节点发起选举的统计（serverStatus.electionMetrics），用于发现频繁的选举")
+	flag.BoolVar(&electionDeltaEnabled, "election-delta", false, "选举统计输出与上一次采集相比的增量而不是累计值，上一次的结果保存在 data-dir 中")
+	flag.BoolVar(&collectTopologyVersion, "collect-topology-version", false, "采集当前连接节点的拓扑版本（hello 响应中的 topologyVersion），拓扑每次变化都会递增，用于排查问题时关联配置变更")
+	flag.StringVar(&memberFilterExpr, "member-filter", "", "只输出匹配的副本集成员（SETTING、REPL_STAT、HOST 等），汇总信息同样只统计这些成员，多个条件使用逗号分隔，以 ! 开头表示排除，支持 id=1、state=SECONDARY、tag:dc=east、voting、hidden，如 voting,!tag:usage=analytics")
+	flag.StringVar(&scopeDatabase, "database", "", "按数据库执行的采集器（角色、DBSTATS、索引等）只采集该数据库，集群级别的信息不受影响，指定后忽略 -exclude-db")
+	flag.StringVar(&excludeDB, "exclude-db", "", "按数据库执行的采集器跳过匹配的数据库，支持通配符，多个使用逗号分隔，如 local,test_*")
+	flag.BoolVar(&collectorTiming, "timing", false, "在日志中打印每个采集器的耗时")
+	flag.BoolVar(&logToStdout, "log-to-stdout", false, "将日志与警告输出到标准输出而不是标准错误输出，与快照、diff 输出在同一个流中")
+	flag.BoolVar(&collectTLSCerts, "collect-tls-certs", false, "启用 TLS 时直连每一个副本集成员读取服务端证书，输出证书的过期时间与剩余天数（保留两位有效数字）")
+	flag.BoolVar(&collectCurrentOp, "collect-currentop", false, "采集当前正在执行的操作数量与执行时间最长的操作已经执行的时间（currentOp），只反映采集时刻的状态，默认不参与 diff")
+	flag.BoolVar(&diffCurrentOp, "diff-currentop", false, "与 -collect-currentop 一起使用，CURRENTOP 参与 diff，每次运行几乎都会产生差异")
+	flag.UintVar(&currentOpWarnSecs, "currentop-warn-secs", 0, "与 -collect-currentop 一起使用，存在执行时间超过该值（秒）的操作时，仍然输出并保存快照，但以非 0 状态码退出，为 0 时不检查")
+	flag.UintVar(&tlsCertWarnDays, "tls-cert-warn-days", 0, "与 -collect-tls-certs 一起使用，存在剩余天数少于该值的证书时，仍然输出并保存快照，但以非 0 状态码退出，为 0 时不检查")
+	flag.BoolVar(&failOnUnhealthy, "fail-on-unhealthy", false, "存在状态不是 PRIMARY、SECONDARY、ARBITER 的副本集成员时，以非 0 状态码退出")
+	flag.BoolVar(&strictPrivileges, "strict", false, "当前用户缺少采集所需的角色时直接失败，而不只是输出警告")
+	flag.StringVar(&x509CertFile, "x509-cert", "", "使用 X.509 证书认证时的客户端证书文件（PEM 格式），authSource 需要为 $external")
+	flag.StringVar(&x509KeyFile, "x509-key", "", "客户端证书私钥文件，为空时从 -x509-cert 指定的文件中读取")
+	flag.StringVar(&tlsCAFile, "tls-ca-file", "", "用于校验服务端证书的 CA 证书文件")
+	flag.DurationVar(&serverSelectionTimeout, "server-selection-timeout", 0, "选择可用节点的超时时间，主节点不可用时可以设置较短的时间以快速失败，为 0 时使用驱动的默认值（或 URI 中的 serverSelectionTimeoutMS）")
+	flag.StringVar(&notifyWebhooks, "notify-webhook", "", "状态发生变化时，以 JSON 格式 POST 通知到这些 URL，多个 URL 使用逗号分隔")
+	flag.StringVar(&notifyRoutesFile, "notify-routes", "", "通知路由配置文件（JSON 格式），按照发生变化的行的前缀将通知发送到不同的 webhook，如 USER、ROLE 的变化发送给安全团队")
+	flag.StringVar(&notifyProxy, "notify-proxy", "", "发送通知时使用的 HTTP 代理，如 http://proxy.example.com:3128")
+	flag.StringVar(&notifyCAFile, "notify-ca-file", "", "发送通知时用于校验服务端证书的 CA 证书文件")
+	flag.BoolVar(&notifyRecovery, "notify-recovery", false, "持续运行模式下（-watch、-serve），状态恢复到发生变化之前时发送恢复通知")
+	flag.DurationVar(&notifyTimeout, "notify-timeout", 10*time.Second, "发送通知的超时时间")
+	flag.StringVar(&postHook, "post-hook", "", "对比完成后使用 sh -c 执行的命令，diff 通过标准输入传递，环境变量 MONGODIFF_CHANGED、MONGODIFF_NAME 分别为是否发生变化与 diff 名称；持续运行模式下只在发生变化时执行")
+	flag.DurationVar(&postHookTimeout, "post-hook-timeout", 30*time.Second, "-post-hook 命令的超时时间")
 
 	flag.Parse()
 
+	if uri := os.Getenv(mongoURIEnv); uri != "" && !isFlagPassed("mongo-uri") {
+		mongoURI = uri
+	}
+
+	if logToStdout {
+		log.SetOutput(redactWriter{w: os.Stdout})
+	}
+
+	if explainMode {
+		explain(os.Stdout)
+		return
+	}
+
+	if collectorsFile != "" {
+		conf, err := loadCustomCollectors(collectorsFile)
+		if err != nil {
+			panic(err)
+		}
+
+		customCollectors, aggregationCollectors = conf.Collectors, conf.Aggregations
+	}
+
+	if batchFile != "" {
+		// 持续运行的模式下子进程不会退出，-serve 的子进程还会监听同一个端口
+		switch {
+		case serveAddr != "":
+			panic(fmt.Errorf("-batch can not be used with -serve"))
+		case watchMode:
+			panic(fmt.Errorf("-batch can not be used with -watch"))
+		case interactive:
+			panic(fmt.Errorf("-batch can not be used with -tui"))
+		}
+
+		conf, err := loadBatchConfig(batchFile)
+		if err != nil {
+			panic(err)
+		}
+
+		exitCode = runBatch(os.Stdout, conf)
+		return
+	}
+
+	if !isFlagPassed("name") {
+		if name := nameFromURI(mongoURI); name != "" {
+			diffName = name
+		}
+	}
+
+	targets, err := parseOutputTargets(outputExpr)
+	if err != nil {
+		panic(err)
+	}
+	outputTargets, outputFormat = targets, targets[0].Format
+
+	// ndjson 输出中包含采集时间，每次输出都不相同，不能用于 diff
+	if outputFormat == "ndjson" && !noDiff {
+		panic(fmt.Errorf("-output ndjson includes the collect time and can not be used for diff, use it with -no-diff or as an additional output"))
+	}
+
+	if err := validateJSONCase(jsonCase); err != nil {
+		panic(err)
+	}
+
+	if jsonIndent, err = parseIndent(indentExpr); err != nil {
+		panic(err)
+	}
+
+	for _, pattern := range strings.Split(excludeDB, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern == "" {
+			continue
+		}
+
+		if _, err := path.Match(pattern, ""); err != nil {
+			panic(fmt.Errorf("invalid -exclude-db pattern %q: %w", pattern, err))
+		}
+
+		excludeDBPatterns = append(excludeDBPatterns, pattern)
+	}
+
+	for _, prefix := range strings.Split(filterPrefixExpr, ",") {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			filterPrefixes = append(filterPrefixes, prefix)
+		}
+	}
+
+	if memberFilter, err = parseMemberFilter(memberFilterExpr); err != nil {
+		panic(err)
+	}
+
+	if hostNamesFile != "" {
+		if hostNames, err = loadHostNames(hostNamesFile); err != nil {
+			panic(err)
+		}
+	}
+
+	if onlyAdded && onlyRemoved {
+		panic(fmt.Errorf("-only-added can not be used with -only-removed"))
+	}
+
+	if parameterNames, err = parseParameterNames(parametersExpr, presetExpr); err != nil {
+		panic(err)
+	}
+
+	tmpl, err := parseTextTemplate(textTemplateExpr)
+	if err != nil {
+		panic(err)
+	}
+	textTemplate = tmpl
+
+	if selectExpr != "" {
+		if !hasOutputFormat("json") {
+			panic(fmt.Errorf("-select requires -output json"))
+		}
+
+		paths, err := ParseSelectPaths(selectExpr)
+		if err != nil {
+			panic(err)
+		}
+
+		if err := ValidateSelectPaths(&Snapshot{}, paths); err != nil {
+			panic(err)
+		}
+		selectPaths = paths
+	}
+
+	if checkMode {
+		report, err := checkCollectors(mongoURI)
+		if err != nil {
+			panic(err)
+		}
+
+		if err := writeCheckReport(os.Stdout, report, outputFormat); err != nil {
+			panic(err)
+		}
+
+		if !report.OK {
+			exitCode = exitError
+		}
+
+		return
+	}
+
+	if notifiers, err = newNotifiers(); err != nil {
+		panic(err)
+	}
+
 	if noDiff {
-		if err := mongoInfo(mongoURI, os.Stdout); err != nil {
+		snapshot, err := collectSnapshot()
+		_, _ = io.WriteString(os.Stdout, display(snapshot))
+		mustCollect(err)
+		return
+	}
+
+	if k8sLease != "" && k8sLeaseDuration < time.Second {
+		panic(fmt.Errorf("-k8s-lease-duration must be at least 1s, got %s", k8sLeaseDuration))
+	}
+
+	if (serveAddr != "" || watchMode) && serveInterval <= 0 {
+		panic(fmt.Errorf("-interval must be greater than 0, got %s", serveInterval))
+	}
+
+	if requireBaseline && baseline {
+		panic(fmt.Errorf("-require-baseline can not be used with -baseline"))
+	}
+
+	if diffCommon > 0 && diffAgainst > 1 {
+		panic(fmt.Errorf("-diff-common can not be used with -diff-against"))
+	}
+
+	var semantic *SemanticJSON
+	if semanticJSON {
+		if outputFormat != "json" {
+			panic(fmt.Errorf("-semantic-json requires -output json"))
+		}
+
+		if diffCommon > 0 {
+			panic(fmt.Errorf("-semantic-json can not be used with -diff-common"))
+		}
+
+		semantic = &SemanticJSON{IgnoreArrayOrder: jsonIgnoreArrayOrder}
+	} else if jsonIgnoreArrayOrder {
+		panic(fmt.Errorf("-json-ignore-array-order requires -semantic-json"))
+	}
+
+	if err := validateVersionFilenameFormat(versionFilenameFormat); err != nil {
+		panic(err)
+	}
+
+	fs := file.LocalFS{}
+	if err := fs.MkDir(dataDir); err != nil {
+		panic(err)
+	}
+
+	if err := loadRedactionKey(dataDir); err != nil {
+		panic(err)
+	}
+
+	tolerance, err := parseNumericTolerance(numericToleranceExpr, numericToleranceKeys)
+	if err != nil {
+		panic(err)
+	}
+
+	differ := NewDiffer(fs, dataDir, int(contextLine)).Reverse(reverseDiff).IgnoreWhitespace(ignoreWhitespace).WithNumericTolerance(tolerance).SemanticJSON(semantic).Exclude(excludePatterns()).StoreHash(snapshotHash).WithMessage(message).DiffAgainst(int(diffAgainst)).DiffCommon(int(diffCommon)).KeepDays(keepDays).KeepInteresting(interestingThreshold, keepInteresting).FilenameFormat(versionFilenameFormat)
+	if nowOverride != "" {
+		now, err := time.Parse(time.RFC3339, nowOverride)
+		if err != nil {
+			panic(fmt.Errorf("invalid -now value: %w", err))
+		}
+
+		differ.WithClock(FixedClock(now))
+	}
+
+	if interactive {
+		if err := runTUI(differ, diffName); err != nil {
 			panic(err)
 		}
 
 		return
 	}
 
+	if showHistory {
+		if err := printHistory(os.Stdout, differ, diffName); err != nil {
+			panic(err)
+		}
+
+		return
+	}
+
+	if compareMode {
+		if flag.NArg() != 2 {
+			panic(fmt.Errorf("-compare-json requires exactly two snapshot files, got %d", flag.NArg()))
+		}
+
+		diffText, err := compareFiles(differ, flag.Arg(0), flag.Arg(1))
+		if err != nil {
+			panic(err)
+		}
+
+		out, flush := pagedStdout()
+		_, _ = io.WriteString(out, displayDiff(diffText))
+		flush()
+		exitChangedIf(diffText != "")
+		return
+	}
+
+	// 多个副本同时运行时（如 Kubernetes CronJob），只有获取到租约的实例执行
+	if k8sLease != "" {
+		elector, err := NewLeaderElector(k8sLease, k8sLeaseDuration)
+		switch {
+		case errors.Is(err, errNotInCluster):
+			log.Printf("not running in a kubernetes cluster, -k8s-lease ignored")
+		case err != nil:
+			panic(err)
+		default:
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			leader, err := elector.TryAcquire(ctx)
+			cancel()
+			if err != nil {
+				panic(err)
+			}
+
+			if !leader {
+				log.Printf("lease %s is held by another instance, skip this run", k8sLease)
+				return
+			}
+
+			// 失去租约时停止 -watch、-serve，并以非 0 状态码退出
+			leaseLost = elector.Lost()
+			defer func() {
+				elector.Release()
+
+				select {
+				case <-leaseLost:
+					exitCode = exitError
+				default:
+				}
+			}()
+		}
+	}
+
+	// 以下操作会写入历史记录，同一个名称同时只允许一个实例运行
+	if importFile != "" {
+		f, err := os.Open(importFile)
+		if err != nil {
+			panic(err)
+		}
+		defer f.Close()
+
+		archive, err := readArchive(f)
+		if err != nil {
+			panic(err)
+		}
+
+		// 未指定 -name 时写入归档中的名称，需要锁定实际写入的名称
+		name := archive.Name
+		if isFlagPassed("name") {
+			name = diffName
+		}
+
+		lock, err := lockHistory(dataDir, name, lockTimeout)
+		if err != nil {
+			panic(err)
+		}
+		defer lock.Unlock()
+
+		if err := importHistory(differ, archive, name); err != nil {
+			panic(err)
+		}
+
+		return
+	}
+
+	lock, err := lockHistory(dataDir, diffName, lockTimeout)
+	if err != nil {
+		panic(err)
+	}
+	defer lock.Unlock()
+
+	if acknowledge {
+		ack, err := differ.Acknowledge(diffName)
+		if err != nil {
+			panic(err)
+		}
+
+		log.Printf("state %s of %s acknowledged", ack.StateHash[:12], diffName)
+		return
+	}
+
+	if exportFile != "" {
+		out := io.Writer(os.Stdout)
+		if exportFile != "-" {
+			f, err := os.Create(exportFile)
+			if err != nil {
+				panic(err)
+			}
+			defer f.Close()
+
+			out = f
+		}
+
+		if err := exportHistory(out, differ, diffName); err != nil {
+			panic(err)
+		}
+
+		return
+	}
+
+	if diffSaved {
+		diffText, err := differ.DiffSaved(diffName)
+		if err != nil {
+			panic(err)
+		}
+
+		out, flush := pagedStdout()
+		_, _ = io.WriteString(out, displayDiff(diffText))
+		flush()
+		exitChangedIf(diffText != "")
+		return
+	}
+
+	if baselineFile != "" {
+		base, err := ioutil.ReadFile(baselineFile)
+		if err != nil {
+			panic(err)
+		}
+
+		snapshot, err := collectSnapshot()
+		mustCollect(err)
+
+		diffText := differ.DiffText(baselineFile, string(base), diffName+".new", snapshot)
+		out, flush := pagedStdout()
+		_, _ = io.WriteString(out, displayDiff(diffText))
+		flush()
+		exitChangedIf(diffText != "")
+		return
+	}
+
+	if collectOnly {
+		snapshot, err := collectSnapshot()
+		mustCollect(err)
+
+		latest := differ.Snapshot(diffName, snapshot)
+		if err := latest.Save(); err != nil {
+			panic(err)
+		}
+
+		cleanVersions(latest)
+		return
+	}
+
+	if requireBaseline {
+		if err := checkBaseline(differ, diffName); err != nil {
+			panic(err)
+		}
+	}
+
+	if serveAddr != "" {
+		if err := serve(serveAddr, serveInterval, differ); err != nil {
+			panic(err)
+		}
+
+		return
+	}
+
+	if watchMode {
+		if err := watch(serveInterval, differ, os.Stdout); err != nil {
+			panic(err)
+		}
+
+		return
+	}
+
+	_, latest, err := collectAndDiff(differ)
+	mustCollect(err)
+
+	if baseline {
+		if err := latest.Save(); err != nil {
+			panic(err)
+		}
+	} else {
+		out, flush := pagedStdout()
+		if err := printAndSave(out, latest); err != nil {
+			panic(err)
+		}
+		flush()
+
+		if snapshotHash {
+			log.Printf("snapshot sha256: %s", latest.Hash())
+		}
+
+		if latest.Changed() && !latest.Acknowledged() {
+			notify(diffName, display(latest.String()))
+		}
+
+		runPostHook(latest.Changed(), display(latest.String()))
+
+		exitChangedIf(latest.Changed())
+	}
+
+	cleanVersions(latest)
+}
+
+// excludePatterns 返回不参与 diff 的内容，只反映采集时刻状态的数据默认不参与 diff
+func excludePatterns() []*regexp.Regexp {
+	if collectCurrentOp && !diffCurrentOp {
+		return currentOpExcludePatterns
+	}
+
+	return nil
+}
+
+// checkBaseline 检查 name 是否已经存在保存的版本，不存在时返回错误
+func checkBaseline(differ *Differ, name string) error {
+	versions, err := differ.Versions(name)
+	if err != nil {
+		return err
+	}
+
+	if len(versions) == 0 {
+		return fmt.Errorf("no saved version found for %s in %s, run with -baseline first", name, dataDir)
+	}
+
+	return nil
+}
+
+// cleanVersions 按照 -keep-version 与 -keep-days 清理历史版本，指定了 -no-clean 时不执行清理
+func cleanVersions(latest Diff) {
+	if noClean {
+		return
+	}
+
+	_ = latest.Clean(keepVersion)
+}
+
+// collectAndDiff 采集 MongoDB 信息，并与最后一次保存的版本进行对比
+func collectAndDiff(differ *Differ) (string, Diff, error) {
+	snapshot, err := collectSnapshot()
+	if err != nil && !isSoftError(err) {
+		return "", Diff{}, err
+	}
+
+	return snapshot, differ.DiffLatest(diffName, snapshot), err
+}
+
+// collectSnapshot 采集 MongoDB 信息，返回按照 -output 格式输出后的快照内容
+// 部分采集器失败时同时返回已采集到的快照内容与 PartialError
+func collectSnapshot() (string, error) {
 	buffer := bytes.NewBuffer(nil)
-	if err := mongoInfo(mongoURI, buffer); err != nil {
-		panic(err)
+	if err := mongoInfo(mongoURI, buffer); err != nil && !isSoftError(err) {
+		return "", err
+	} else if err != nil {
+		return buffer.String(), err
 	}
 
-	fs := file.LocalFS{}
-	if err := fs.MkDir(dataDir); err != nil {
-		panic(err)
+	return buffer.String(), nil
+}
+
+// mongoInfo 采集并输出快照，部分采集器失败时仍然输出已采集到的数据，并返回 PartialError
+func mongoInfo(mongoURI string, out io.Writer) error {
+	snapshot, err := collect(mongoURI)
+	if snapshot == nil {
+		return err
 	}
 
-	differ := diff.NewDiffer(fs, dataDir, int(contextLine))
-	latest := differ.DiffLatest(diffName, buffer.String())
-	if err := latest.PrintAndSave(os.Stdout); err != nil {
-		panic(err)
+	if writeErr := writeSnapshot(out, snapshot); writeErr != nil {
+		return writeErr
 	}
 
-	_ = latest.Clean(keepVersion)
+	if writeErr := writeExtraOutputs(snapshot); writeErr != nil {
+		return writeErr
+	}
+
+	return err
 }
 
-func mongoInfo(mongoURI string, out io.Writer) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+// isFlagPassed 判断命令行中是否显式指定了参数
+func isFlagPassed(name string) bool {
+	passed := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			passed = true
+		}
+	})
 
-	clientOption := options.Client().ApplyURI(mongoURI)
-	connect, err := mongo.Connect(ctx, clientOption)
-	if err != nil {
-		return err
+	return passed
+}
+
+var nameSanitizeRegexp = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// nameFromURI 使用 MongoDB URI 中第一个主机的主机名作为 Diff 名称，避免多个集群共用同一份历史记录
+// 本机地址以及无法解析的 URI 返回空字符串
+func nameFromURI(mongoURI string) string {
+	u, err := url.Parse(mongoURI)
+	if err != nil || u.Host == "" {
+		return ""
 	}
-	defer connect.Disconnect(context.TODO())
 
-	mm := NewMongoManager(connect)
-	databaseNames, err := mm.AllDatabaseNames(ctx)
-	if err != nil {
-		return err
+	host := strings.Split(u.Host, ",")[0]
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
 	}
-	for _, name := range databaseNames {
-		_, _ = fmt.Fprintf(out, "DB: %s\n", name)
+
+	switch host {
+	case "", "localhost", "127.0.0.1", "::1":
+		return ""
 	}
 
-	users, err := mm.AllUsers(ctx)
-	if err != nil {
-		return err
+	return strings.Trim(nameSanitizeRegexp.ReplaceAllString(host, "-"), "-")
+}
+
+// hasOutputFormat 判断 -output 中是否包含指定的格式
+func hasOutputFormat(format string) bool {
+	for _, target := range outputTargets {
+		if target.Format == format {
+			return true
+		}
+	}
+
+	return false
+}
+
+// newClientOptions 创建 MongoDB 连接配置，指定了 -x509-cert 时使用 X.509 证书认证
+func newClientOptions(mongoURI string) (*options.ClientOptions, error) {
+	clientOption := options.Client().ApplyURI(mongoURI)
+	if serverSelectionTimeout > 0 {
+		clientOption.SetServerSelectionTimeout(serverSelectionTimeout)
 	}
-	for _, user := range users {
-		_, _ = fmt.Fprintf(out, "USER: db=%s, user=%s\n", user.DB, user.User)
-		for _, role := range user.Roles {
-			_, _ = fmt.Fprintf(out, "USER_ROLE: db=%s, user=%s, role=%s/%s\n", user.DB, user.User, role.DB, role.Role)
+
+	if x509CertFile == "" {
+		if tlsCAFile != "" {
+			tlsConfig, err := newTLSConfig("", "", tlsCAFile)
+			if err != nil {
+				return nil, err
+			}
+			clientOption.SetTLSConfig(tlsConfig)
 		}
+
+		return clientOption, nil
+	}
+
+	keyFile := x509KeyFile
+	if keyFile == "" {
+		keyFile = x509CertFile
 	}
 
-	conf, err := mm.Config(ctx)
+	tlsConfig, err := newTLSConfig(x509CertFile, keyFile, tlsCAFile)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	for _, setting := range conf.Members {
-		_, _ = fmt.Fprintf(out, "SETTING: id=%d, host=%s, vote=%d, arbiterOnly=%v, buildIndexes=%v, hidden=%v, priority=%d\n", setting.ID, setting.Host, setting.Votes, setting.ArbiterOnly, setting.BuildIndexes, setting.Hidden, setting.Priority)
+
+	// X.509 认证的用户名为客户端证书的 subject，用户存储在 $external 数据库中
+	return clientOption.SetTLSConfig(tlsConfig).SetAuth(options.Credential{
+		AuthMechanism: "MONGODB-X509",
+		AuthSource:    "$external",
+	}), nil
+}
+
+func newTLSConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate failed: %w", err)
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{cert}
 	}
 
-	replStat, err := mm.ReplStatus(ctx)
-	if err != nil {
-		return err
+	if caFile != "" {
+		data, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("read ca file failed: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no valid certificate found in ca file %s", caFile)
+		}
+
+		tlsConfig.RootCAs = pool
 	}
-	for _, stat := range replStat.Members {
-		_, _ = fmt.Fprintf(out, "REPL_STAT: id=%d, name=%s, state=%s, health=%d, syncSourceHost=%s, syncingTo=%s\n", stat.ID, stat.Name, stat.StateStr, stat.Health, stat.SyncSourceHost, stat.SyncingTo)
+
+	return tlsConfig, nil
+}
+
+// optionalBool 格式化可选的布尔配置项，未配置时输出 default
+func optionalBool(val *bool) string {
+	if val == nil {
+		return "default"
 	}
 
-	return nil
+	return fmt.Sprintf("%v", *val)
 }
 
 type MongoManager struct {
-	conn *mongo.Client
+	conn        *mongo.Client
+	cmdLineOpts *CmdLineOpts
 }
 
 func NewMongoManager(conn *mongo.Client) *MongoManager {
@@ -120,15 +950,70 @@ func (mm *MongoManager) AllDatabaseNames(ctx context.Context) ([]string, error)
 	return mm.conn.ListDatabaseNames(ctx, bson.M{})
 }
 
+// AllDatabases 返回所有数据库及其占用的磁盘空间
+func (mm *MongoManager) AllDatabases(ctx context.Context) ([]DatabaseSize, error) {
+	res, err := mm.conn.ListDatabases(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+
+	databases := make([]DatabaseSize, 0, len(res.Databases))
+	for _, db := range res.Databases {
+		databases = append(databases, DatabaseSize{Name: db.Name, SizeOnDiskMB: roundSizeMB(float64(db.SizeOnDisk)), Empty: db.Empty})
+	}
+
+	return databases, nil
+}
+
 func (mm *MongoManager) AllUsers(ctx context.Context) ([]User, error) {
 	var users UsersResp
 	if err := mm.conn.Database("admin").RunCommand(ctx, bson.M{"usersInfo": bson.M{"forAllDBs": true}}).Decode(&users); err != nil {
 		return nil, err
 	}
 
+	// 服务端返回的角色顺序并不固定，排序后避免产生错误的差异
+	for _, user := range users.Users {
+		sortRoles(user.Roles)
+	}
+
 	return users.Users, nil
 }
 
+// AllRoles 返回所有数据库中的自定义角色，角色及其继承的角色均按照 db、role 排序
+func (mm *MongoManager) AllRoles(ctx context.Context, databaseNames []string) ([]CustomRole, error) {
+	roles := make([]CustomRole, 0)
+	for _, name := range databaseNames {
+		var resp RolesResp
+		if err := mm.conn.Database(name).RunCommand(ctx, bson.M{"rolesInfo": 1}).Decode(&resp); err != nil {
+			return nil, err
+		}
+
+		roles = append(roles, resp.Roles...)
+	}
+
+	for _, role := range roles {
+		sortRoles(role.Roles)
+	}
+	sort.Slice(roles, func(i, j int) bool {
+		if roles[i].DB != roles[j].DB {
+			return roles[i].DB < roles[j].DB
+		}
+
+		return roles[i].Role < roles[j].Role
+	})
+
+	return roles, nil
+}
+
+func (mm *MongoManager) DBStats(ctx context.Context, name string) (DBStatsResp, error) {
+	var stats DBStatsResp
+	if err := mm.conn.Database(name).RunCommand(ctx, bson.M{"dbStats": 1}).Decode(&stats); err != nil {
+		return DBStatsResp{}, err
+	}
+
+	return stats, nil
+}
+
 func (mm *MongoManager) Config(ctx context.Context) (ReplSetConfig, error) {
 	var replConf ReplSetConfigResp
 	if err := mm.conn.Database("admin").RunCommand(ctx, bson.M{"replSetGetConfig": 1}).Decode(&replConf); err != nil {
@@ -147,6 +1032,108 @@ func (mm *MongoManager) ReplStatus(ctx context.Context) (ReplSetStatus, error) {
 	return replSetStatus, nil
 }
 
+func (mm *MongoManager) ServerStatus(ctx context.Context) (ServerStatus, error) {
+	var serverStatus ServerStatus
+	if err := mm.conn.Database("admin").RunCommand(ctx, bson.M{"serverStatus": 1}).Decode(&serverStatus); err != nil {
+		return ServerStatus{}, err
+	}
+
+	return serverStatus, nil
+}
+
+// AllMongos 返回 config.mongos 中记录的 mongos 路由，maxPingAge 大于 0 时过滤掉长时间没有 ping 的记录
+func (mm *MongoManager) AllMongos(ctx context.Context, maxPingAge time.Duration) ([]Mongos, error) {
+	cursor, err := mm.conn.Database("config").Collection("mongos").Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+
+	var all []Mongos
+	if err := cursor.All(ctx, &all); err != nil {
+		return nil, err
+	}
+
+	mongos := make([]Mongos, 0, len(all))
+	for _, m := range all {
+		if maxPingAge > 0 && time.Since(m.Ping) > maxPingAge {
+			continue
+		}
+
+		mongos = append(mongos, m)
+	}
+
+	return mongos, nil
+}
+
+// OplogSize 返回 local.oplog.rs 配置的最大容量，没有 oplog 时（如 mongos、单机）返回 nil
+func (mm *MongoManager) OplogSize(ctx context.Context) (*OplogSize, error) {
+	var stats CollStats
+	if err := mm.conn.Database("local").RunCommand(ctx, bson.M{"collStats": "oplog.rs"}).Decode(&stats); err != nil {
+		if isCommandError(err, errCodeNamespaceNotFound) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return &OplogSize{MaxMB: stats.MaxSize / 1024 / 1024}, nil
+}
+
+// CmdLineOpts 返回服务启动时的命令行参数与配置，多个采集器共用，结果只查询一次
+func (mm *MongoManager) CmdLineOpts(ctx context.Context) (CmdLineOpts, error) {
+	if mm.cmdLineOpts != nil {
+		return *mm.cmdLineOpts, nil
+	}
+
+	raw, err := mm.conn.Database("admin").RunCommand(ctx, bson.M{"getCmdLineOpts": 1}).DecodeBytes()
+	if err != nil {
+		return CmdLineOpts{}, err
+	}
+
+	var opts CmdLineOpts
+	if err := bson.Unmarshal(raw, &opts); err != nil {
+		return CmdLineOpts{}, err
+	}
+
+	if parsed, ok := raw.Lookup("parsed").DocumentOK(); ok {
+		if err := bson.Unmarshal(parsed, &opts.ParsedRaw); err != nil {
+			return CmdLineOpts{}, err
+		}
+	}
+
+	mm.cmdLineOpts = &opts
+	return opts, nil
+}
+
+// Hello 返回当前连接节点的 hello 响应，服务端不支持 hello 命令时（4.4.2 之前的版本）使用 isMaster 代替
+func (mm *MongoManager) Hello(ctx context.Context) (Hello, error) {
+	var hello Hello
+	err := mm.conn.Database("admin").RunCommand(ctx, bson.M{"hello": 1}).Decode(&hello)
+	if isCommandError(err, errCodeCommandNotFound) {
+		err = mm.conn.Database("admin").RunCommand(ctx, bson.M{"isMaster": 1}).Decode(&hello)
+	}
+
+	if err != nil {
+		return Hello{}, err
+	}
+
+	return hello, nil
+}
+
+// FreeMonitoring 返回云端免费监控的状态，服务端不支持 getFreeMonitoringStatus 时（如 mongos、7.0 之后的版本、企业版）返回 nil
+func (mm *MongoManager) FreeMonitoring(ctx context.Context) (*FreeMonitoring, error) {
+	var status FreeMonitoring
+	if err := mm.conn.Database("admin").RunCommand(ctx, bson.M{"getFreeMonitoringStatus": 1}).Decode(&status); err != nil {
+		if isCommandError(err, errCodeCommandNotFound) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return &status, nil
+}
+
 type UsersResp struct {
 	Users []User `bson:"users" json:"users"`
 }
@@ -164,21 +1151,269 @@ type Role struct {
 	Role string `bson:"role" json:"role"`
 }
 
+type RolesResp struct {
+	Roles []CustomRole `bson:"roles" json:"roles"`
+}
+
+type CustomRole struct {
+	DB        string `bson:"db" json:"db"`
+	Role      string `bson:"role" json:"role"`
+	IsBuiltin bool   `bson:"isBuiltin" json:"is_builtin"`
+	Roles     []Role `bson:"roles" json:"roles"`
+}
+
+func sortRoles(roles []Role) {
+	sort.Slice(roles, func(i, j int) bool {
+		if roles[i].DB != roles[j].DB {
+			return roles[i].DB < roles[j].DB
+		}
+
+		return roles[i].Role < roles[j].Role
+	})
+}
+
+// Snapshot 一次采集得到的 MongoDB 信息
+type Snapshot struct {
+	Summary           Summary               `json:"summary"`
+	Databases         []string              `json:"databases"`
+	DatabaseSizes     []DatabaseSize        `json:"database_sizes,omitempty"`
+	Users             []User                `json:"users"`
+	Roles             []CustomRole          `json:"roles"`
+	Members           []ReplSetMemberConfig `json:"members"`
+	WriteConcernModes []WriteConcernMode    `json:"write_concern_modes,omitempty"`
+	ReplSettings      []ReplSetting         `json:"repl_settings,omitempty"`
+	ReplStats         []ReplMemberStat      `json:"repl_stats"`
+	Elections         *ElectionMetrics      `json:"elections,omitempty"`
+	Unhealthy         []UnhealthyMember     `json:"unhealthy_members,omitempty"`
+	NoElect           []NoElectMember       `json:"no_elect_members,omitempty"`
+	DBStats           []DBStats             `json:"dbstats,omitempty"`
+	CollCounts        []DBCollCount         `json:"coll_counts,omitempty"`
+	Indexes           []Index               `json:"indexes,omitempty"`
+	IndexSizes        []IndexSize           `json:"index_sizes,omitempty"`
+	DupIndexes        []DupIndex            `json:"duplicate_indexes,omitempty"`
+	TLSCerts          []TLSCert             `json:"tls_certs,omitempty"`
+	Hosts             []HostInfo            `json:"hosts,omitempty"`
+	Mongos            []Mongos              `json:"mongos"`
+	Chunks            []ChunkCount          `json:"chunks,omitempty"`
+	Zones             []Zone                `json:"zones,omitempty"`
+	Migrations        *Migrations           `json:"migrations,omitempty"`
+	Hello             *Hello                `json:"hello,omitempty"`
+	TopologyVersion   *TopologyVersion      `json:"topology_version,omitempty"`
+	Storage           StorageSettings       `json:"storage"`
+	Checkpoint        *CheckpointSettings   `json:"checkpoint,omitempty"`
+	Transactions      *TransactionSettings  `json:"transactions,omitempty"`
+	CurrentOp         *CurrentOpSummary     `json:"current_op,omitempty"`
+	OplogSize         *OplogSize            `json:"oplog_size,omitempty"`
+	Scripting         ScriptingSettings     `json:"scripting"`
+	FreeMonitoring    *FreeMonitoring       `json:"free_monitoring,omitempty"`
+	AuthProviders     []AuthProviderSetting `json:"auth_providers,omitempty"`
+	CmdLine           []CmdLineOption       `json:"cmdline,omitempty"`
+	Parameters        []Parameter           `json:"parameters,omitempty"`
+	Commands          []CommandAvailability `json:"commands,omitempty"`
+	ClusterParams     []ClusterParam        `json:"cluster_params,omitempty"`
+	ChangeStream      *ChangeStreamOptions  `json:"change_stream,omitempty"`
+	Aggregations      []AggregationLine     `json:"aggregations,omitempty"`
+	Custom            []CustomLine          `json:"custom,omitempty"`
+	Errors            []CollectorError      `json:"errors,omitempty"`
+}
+
+// DatabaseSize 数据库占用的磁盘空间（-collect-db-sizes）
+type DatabaseSize struct {
+	Name         string  `json:"name"`
+	SizeOnDiskMB float64 `json:"size_on_disk_mb"`
+	Empty        bool    `json:"empty"`
+}
+
+// Summary 快照中各类对象的数量统计
+type Summary struct {
+	Databases int `json:"databases"`
+	Users     int `json:"users"`
+	Roles     int `json:"roles"`
+	Members   int `json:"members"`
+}
+
+// Summarize 根据快照中已采集的数据计算数量统计
+func (s *Snapshot) Summarize() Summary {
+	return Summary{
+		Databases: len(s.Databases),
+		Users:     len(s.Users),
+		Roles:     len(s.Roles),
+		Members:   len(s.Members),
+	}
+}
+
+// ReplMemberStat 副本集成员状态中相对稳定的字段
+type ReplMemberStat struct {
+	ID             int    `json:"id"`
+	Name           string `json:"name"`
+	State          string `json:"state"`
+	Health         int    `json:"health"`
+	SyncSourceHost string `json:"sync_source_host"`
+	SyncingTo      string `json:"syncing_to"`
+}
+
+// UnhealthyMember 状态异常（如长时间处于 RECOVERING、STARTUP）的副本集成员
+type UnhealthyMember struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+}
+
+// healthyStates 正常的副本集成员状态
+var healthyStates = map[string]bool{"PRIMARY": true, "SECONDARY": true, "ARBITER": true}
+
+// UnhealthyMembers 根据副本集成员状态计算状态异常的成员
+func (s *Snapshot) UnhealthyMembers() []UnhealthyMember {
+	members := make([]UnhealthyMember, 0)
+	for _, stat := range s.ReplStats {
+		if !healthyStates[stat.State] {
+			members = append(members, UnhealthyMember{Name: stat.Name, State: stat.State})
+		}
+	}
+
+	return members
+}
+
+// NoElectMember priority 为 0、永远不会被选为主节点的成员，不包括按照设计必须为 0 的仲裁节点、隐藏节点与延迟节点
+type NoElectMember struct {
+	ID   int    `json:"id"`
+	Host string `json:"host"`
+}
+
+// NoElectMembers 根据副本集配置计算不能被选为主节点的成员
+func (s *Snapshot) NoElectMembers() []NoElectMember {
+	members := make([]NoElectMember, 0)
+	for _, member := range s.Members {
+		if member.Priority == 0 && !member.ArbiterOnly && !member.Hidden && member.SlaveDelay == 0 {
+			members = append(members, NoElectMember{ID: member.ID, Host: member.Host})
+		}
+	}
+
+	return members
+}
+
+// StorageSettings 存储引擎持久化相关配置
+type StorageSettings struct {
+	Engine         string `json:"engine"`
+	JournalEnabled *bool  `json:"journal_enabled"`
+	DirectoryPerDB *bool  `json:"directory_per_db"`
+	Persistent     bool   `json:"persistent"`
+	ReadOnly       bool   `json:"read_only"`
+}
+
+// CheckpointSettings checkpoint 与 journal 提交间隔，决定了崩溃恢复时可能需要重放的数据量
+type CheckpointSettings struct {
+	// IntervalSecs checkpoint 间隔（syncdelay），单位秒
+	IntervalSecs string `json:"interval_secs"`
+	// JournalCommitIntervalMs journal 提交间隔，单位毫秒，未上报时为空
+	JournalCommitIntervalMs string `json:"journal_commit_interval_ms"`
+}
+
+// Checkpoint 返回 checkpoint 相关的服务端参数，服务端没有上报（如 mongos）时返回 nil
+func (mm *MongoManager) Checkpoint(ctx context.Context) (*CheckpointSettings, error) {
+	params, err := mm.Parameters(ctx, []string{"syncdelay", "journalCommitInterval"})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(params) == 0 {
+		return nil, nil
+	}
+
+	settings := &CheckpointSettings{}
+	for _, param := range params {
+		switch param.Name {
+		case "syncdelay":
+			settings.IntervalSecs = param.Value
+		case "journalCommitInterval":
+			settings.JournalCommitIntervalMs = param.Value
+		}
+	}
+
+	return settings, nil
+}
+
 type ReplSetConfig struct {
 	ID              string                `bson:"_id" json:"id"`
 	Members         []ReplSetMemberConfig `bson:"members" json:"members"`
 	ProtocolVersion int                   `bson:"protocolVersion" json:"protocol_version"`
+	Settings        ReplSetSettings       `bson:"settings" json:"settings"`
+}
+
+type ReplSetSettings struct {
+	GetLastErrorModes          map[string]map[string]int `bson:"getLastErrorModes" json:"get_last_error_modes"`
+	ChainingAllowed            *bool                     `bson:"chainingAllowed" json:"chaining_allowed,omitempty"`
+	HeartbeatIntervalMillis    *int64                    `bson:"heartbeatIntervalMillis" json:"heartbeat_interval_millis,omitempty"`
+	HeartbeatTimeoutSecs       *int64                    `bson:"heartbeatTimeoutSecs" json:"heartbeat_timeout_secs,omitempty"`
+	ElectionTimeoutMillis      *int64                    `bson:"electionTimeoutMillis" json:"election_timeout_millis,omitempty"`
+	CatchUpTimeoutMillis       *int64                    `bson:"catchUpTimeoutMillis" json:"catch_up_timeout_millis,omitempty"`
+	CatchUpTakeoverDelayMillis *int64                    `bson:"catchUpTakeoverDelayMillis" json:"catch_up_takeover_delay_millis,omitempty"`
+}
+
+// ReplSetting 副本集配置 settings 中影响选举与故障切换的配置项
+type ReplSetting struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// replSettings 返回副本集配置 settings 中已配置的选举与心跳相关配置项，按照 key 排序，保证输出稳定
+func replSettings(settings ReplSetSettings) []ReplSetting {
+	values := map[string]interface{}{
+		"chainingAllowed":            settings.ChainingAllowed,
+		"heartbeatIntervalMillis":    settings.HeartbeatIntervalMillis,
+		"heartbeatTimeoutSecs":       settings.HeartbeatTimeoutSecs,
+		"electionTimeoutMillis":      settings.ElectionTimeoutMillis,
+		"catchUpTimeoutMillis":       settings.CatchUpTimeoutMillis,
+		"catchUpTakeoverDelayMillis": settings.CatchUpTakeoverDelayMillis,
+	}
+
+	result := make([]ReplSetting, 0, len(values))
+	for key, value := range values {
+		switch v := value.(type) {
+		case *bool:
+			if v != nil {
+				result = append(result, ReplSetting{Key: key, Value: strconv.FormatBool(*v)})
+			}
+		case *int64:
+			if v != nil {
+				result = append(result, ReplSetting{Key: key, Value: strconv.FormatInt(*v, 10)})
+			}
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Key < result[j].Key })
+	return result
+}
+
+// WriteConcernMode 副本集配置中自定义的 write concern 模式
+type WriteConcernMode struct {
+	Name string `json:"name"`
+	// Def 模式的定义，为按照标签名排序的 JSON，如 {"dc":2}
+	Def string `json:"def"`
+}
+
+// writeConcernModes 将自定义的 write concern 模式按照名称排序，保证输出稳定
+func writeConcernModes(modes map[string]map[string]int) []WriteConcernMode {
+	result := make([]WriteConcernMode, 0, len(modes))
+	for name, def := range modes {
+		// encoding/json 序列化 map 时按照 key 排序
+		data, _ := json.Marshal(def)
+		result = append(result, WriteConcernMode{Name: name, Def: string(data)})
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
 }
 
 type ReplSetMemberConfig struct {
-	ID           int    `bson:"_id" json:"id"`
-	ArbiterOnly  bool   `bson:"arbiterOnly" json:"arbiter_only"`
-	BuildIndexes bool   `bson:"buildIndexes" json:"build_indexes"`
-	Hidden       bool   `bson:"hidden" json:"hidden"`
-	Host         string `bson:"host" json:"host"`
-	Priority     int    `bson:"priority" json:"priority"`
-	SlaveDelay   int    `bson:"slaveDelay" json:"slave_delay"`
-	Votes        int    `bson:"votes" json:"votes"`
+	ID           int               `bson:"_id" json:"id"`
+	ArbiterOnly  bool              `bson:"arbiterOnly" json:"arbiter_only"`
+	BuildIndexes bool              `bson:"buildIndexes" json:"build_indexes"`
+	Hidden       bool              `bson:"hidden" json:"hidden"`
+	Host         string            `bson:"host" json:"host"`
+	Priority     int               `bson:"priority" json:"priority"`
+	SlaveDelay   int               `bson:"slaveDelay" json:"slave_delay"`
+	Votes        int               `bson:"votes" json:"votes"`
+	Tags         map[string]string `bson:"tags" json:"tags"`
 }
 
 type ReplSetConfigResp struct {
@@ -217,6 +1452,172 @@ type ReplMember struct {
 	PingMS               int       `bson:"pingMs" json:"ping_ms"`
 }
 
+type Mongos struct {
+	Host         string    `bson:"_id" json:"host"`
+	MongoVersion string    `bson:"mongoVersion" json:"mongo_version"`
+	Ping         time.Time `bson:"ping" json:"-"`
+}
+
+type DBStatsResp struct {
+	DB          string  `bson:"db" json:"db"`
+	Collections int     `bson:"collections" json:"collections"`
+	Objects     int64   `bson:"objects" json:"objects"`
+	DataSize    float64 `bson:"dataSize" json:"data_size"`
+	StorageSize float64 `bson:"storageSize" json:"storage_size"`
+	Indexes     int     `bson:"indexes" json:"indexes"`
+	IndexSize   float64 `bson:"indexSize" json:"index_size"`
+}
+
+// DBStats 数据库统计信息，数据量保留两位有效数字
+type DBStats struct {
+	DB          string  `json:"db"`
+	Collections int     `json:"collections"`
+	DataSizeMB  float64 `json:"data_size_mb"`
+	Indexes     int     `json:"indexes"`
+}
+
+type CollStats struct {
+	NS      string `bson:"ns" json:"ns"`
+	Capped  bool   `bson:"capped" json:"capped"`
+	MaxSize int64  `bson:"maxSize" json:"max_size"`
+}
+
+// ScriptingSettings 服务端 JavaScript 脚本相关配置
+type ScriptingSettings struct {
+	JavascriptEnabled *bool `json:"javascript_enabled"`
+}
+
+// FreeMonitoring 云端免费监控（遥测）的状态
+type FreeMonitoring struct {
+	// State 状态，包括 enabled、disabled、undecided
+	State string `bson:"state" json:"state"`
+}
+
+// OplogSize oplog 配置的最大容量
+type OplogSize struct {
+	MaxMB int64 `json:"max_mb"`
+}
+
+// Hello 当前连接节点视角下的拓扑信息
+type Hello struct {
+	Primary        string `bson:"primary" json:"primary"`
+	Me             string `bson:"me" json:"me"`
+	SetName        string `bson:"setName" json:"set_name"`
+	MinWireVersion int32  `bson:"minWireVersion" json:"min_wire_version"`
+	MaxWireVersion int32  `bson:"maxWireVersion" json:"max_wire_version"`
+	// Msg 连接到 mongos 时为 isdbgrid
+	Msg string `bson:"msg" json:"-"`
+	// TopologyVersion 每次拓扑变化时递增，只有开启 -collect-topology-version 时才输出
+	TopologyVersion *HelloTopologyVersion `bson:"topologyVersion" json:"-"`
+}
+
+// IsMongos 当前连接的节点是否为 mongos
+func (h Hello) IsMongos() bool {
+	return h.Msg == "isdbgrid"
+}
+
+type HelloTopologyVersion struct {
+	ProcessID primitive.ObjectID `bson:"processId"`
+	Counter   int64              `bson:"counter"`
+}
+
+// TopologyVersion 当前连接节点的拓扑版本，processId 在进程重启后变化，counter 在拓扑发生变化时递增
+type TopologyVersion struct {
+	ProcessID string `json:"process_id"`
+	Counter   int64  `json:"counter"`
+}
+
+type ServerStatus struct {
+	Host          string        `bson:"host" json:"host"`
+	Version       string        `bson:"version" json:"version"`
+	StorageEngine StorageEngine `bson:"storageEngine" json:"storage_engine"`
+	// ElectionMetrics 只在副本集成员上存在
+	ElectionMetrics *ElectionMetricsResp `bson:"electionMetrics" json:"-"`
+}
+
+type StorageEngine struct {
+	Name                        string `bson:"name" json:"name"`
+	Persistent                  bool   `bson:"persistent" json:"persistent"`
+	ReadOnly                    bool   `bson:"readOnly" json:"read_only"`
+	SupportsCommittedReads      bool   `bson:"supportsCommittedReads" json:"supports_committed_reads"`
+	SupportsSnapshotReadConcern bool   `bson:"supportsSnapshotReadConcern" json:"supports_snapshot_read_concern"`
+}
+
+type CmdLineOpts struct {
+	Argv   []string          `bson:"argv" json:"argv"`
+	Parsed CmdLineOptsParsed `bson:"parsed" json:"parsed"`
+	// ParsedRaw 完整的 parsed 配置，用于输出所有的启动参数
+	ParsedRaw bson.M `bson:"-" json:"-"`
+}
+
+type CmdLineOptsParsed struct {
+	Storage      CmdLineStorage  `bson:"storage" json:"storage"`
+	Security     CmdLineSecurity `bson:"security" json:"security"`
+	SetParameter bson.M          `bson:"setParameter" json:"set_parameter"`
+}
+
+type CmdLineSecurity struct {
+	JavascriptEnabled *bool  `bson:"javascriptEnabled" json:"javascript_enabled"`
+	Authorization     string `bson:"authorization" json:"authorization"`
+	ClusterAuthMode   string `bson:"clusterAuthMode" json:"cluster_auth_mode"`
+	LDAP              bson.M `bson:"ldap" json:"ldap"`
+	SASL              bson.M `bson:"sasl" json:"sasl"`
+}
+
+type CmdLineStorage struct {
+	DBPath         string         `bson:"dbPath" json:"db_path"`
+	DirectoryPerDB *bool          `bson:"directoryPerDB" json:"directory_per_db"`
+	Engine         string         `bson:"engine" json:"engine"`
+	Journal        CmdLineJournal `bson:"journal" json:"journal"`
+}
+
+type CmdLineJournal struct {
+	Enabled *bool `bson:"enabled" json:"enabled"`
+}
+
+const (
+	errCodeIllegalOperation  = 20
+	errCodeNamespaceNotFound = 26
+	errCodeCommandNotFound   = 59
+)
+
+// isCommandError 判断 err 是否为指定错误码的 MongoDB 命令错误
+func isCommandError(err error, codes ...int32) bool {
+	var cmdErr mongo.CommandError
+	if !errors.As(err, &cmdErr) {
+		return false
+	}
+
+	for _, code := range codes {
+		if cmdErr.Code == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+// signalContext 返回一个在收到 SIGINT、SIGTERM 信号或者失去 -k8s-lease 租约时取消的 context
+func signalContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		defer signal.Stop(sigs)
+
+		select {
+		case <-sigs:
+			cancel()
+		case <-leaseLost:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, cancel
+}
+
 func NoError(err error) {
 	if err != nil {
 		panic(err)
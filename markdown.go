@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// markdownCollapseLines diff 超过该行数时使用 <details> 折叠
+const markdownCollapseLines = 50
+
+// markdownDiff 将 unified diff 渲染为 GitHub 风格的 markdown，包含一行变化统计与 diff 代码块，适用于 PR 评论
+func markdownDiff(name, unified string) string {
+	if unified == "" {
+		return ""
+	}
+
+	added, removed := diffLineCounts(unified)
+	lines := strings.Split(strings.TrimSuffix(unified, "\n"), "\n")
+
+	// 代码块的分隔符需要比 diff 中出现的反引号更长
+	fence := "```"
+	for strings.Contains(unified, fence) {
+		fence += "`"
+	}
+
+	summary := fmt.Sprintf("**mongo-diff `%s`**: %d line(s) added, %d line(s) removed", name, added, removed)
+	block := fence + "diff\n" + strings.TrimSuffix(unified, "\n") + "\n" + fence + "\n"
+
+	if len(lines) <= markdownCollapseLines {
+		return summary + "\n\n" + block
+	}
+
+	return summary + fmt.Sprintf("\n\n<details>\n<summary>Show diff (%d lines)</summary>\n\n", len(lines)) + block + "\n</details>\n"
+}
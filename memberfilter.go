@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MemberFilter 副本集成员过滤条件（-member-filter），存在包含条件时只保留匹配任意一个包含条件的成员，匹配任意一个排除条件的成员被移除
+type MemberFilter struct {
+	include []memberCondition
+	exclude []memberCondition
+}
+
+// memberCondition 对成员配置与状态（stateStr）的判断
+type memberCondition func(member ReplSetMemberConfig, state string) bool
+
+// parseMemberFilter 解析 -member-filter 参数，多个条件使用逗号分隔，以 ! 开头的条件为排除条件，支持：
+//
+//	id=1          成员 _id
+//	state=PRIMARY 成员状态（replSetGetStatus 中的 stateStr），不区分大小写
+//	tag:dc=east   成员标签
+//	voting        有投票权（votes > 0）的成员
+//	hidden        隐藏成员
+func parseMemberFilter(expr string) (*MemberFilter, error) {
+	if strings.TrimSpace(expr) == "" {
+		return nil, nil
+	}
+
+	filter := &MemberFilter{}
+	for _, term := range strings.Split(expr, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+
+		exclude := strings.HasPrefix(term, "!")
+		cond, err := parseMemberCondition(strings.TrimPrefix(term, "!"))
+		if err != nil {
+			return nil, err
+		}
+
+		if exclude {
+			filter.exclude = append(filter.exclude, cond)
+		} else {
+			filter.include = append(filter.include, cond)
+		}
+	}
+
+	return filter, nil
+}
+
+func parseMemberCondition(term string) (memberCondition, error) {
+	switch term {
+	case "voting":
+		return func(member ReplSetMemberConfig, state string) bool { return member.Votes > 0 }, nil
+	case "hidden":
+		return func(member ReplSetMemberConfig, state string) bool { return member.Hidden }, nil
+	}
+
+	segs := strings.SplitN(term, "=", 2)
+	if len(segs) != 2 {
+		return nil, fmt.Errorf("invalid -member-filter condition %q", term)
+	}
+
+	key, value := segs[0], segs[1]
+	switch {
+	case key == "id":
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -member-filter condition %q: id must be an integer", term)
+		}
+
+		return func(member ReplSetMemberConfig, state string) bool { return member.ID == id }, nil
+	case key == "state":
+		return func(member ReplSetMemberConfig, state string) bool { return strings.EqualFold(state, value) }, nil
+	case strings.HasPrefix(key, "tag:") && len(key) > len("tag:"):
+		tag := strings.TrimPrefix(key, "tag:")
+		return func(member ReplSetMemberConfig, state string) bool { return member.Tags[tag] == value }, nil
+	}
+
+	return nil, fmt.Errorf("invalid -member-filter condition %q: supported conditions are id=, state=, tag:<name>=, voting and hidden", term)
+}
+
+// Match 判断成员是否需要保留
+func (f *MemberFilter) Match(member ReplSetMemberConfig, state string) bool {
+	for _, cond := range f.exclude {
+		if cond(member, state) {
+			return false
+		}
+	}
+
+	if len(f.include) == 0 {
+		return true
+	}
+
+	for _, cond := range f.include {
+		if cond(member, state) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Apply 从快照中移除不需要的成员，包括成员配置、成员状态以及成员所在主机的信息，需要在计算汇总信息之前执行
+func (f *MemberFilter) Apply(snapshot *Snapshot) {
+	// 非副本集时没有成员信息，TLS 证书等来自 URI 中的主机，不做过滤
+	if len(snapshot.Members) == 0 {
+		return
+	}
+
+	states := make(map[int]string)
+	for _, stat := range snapshot.ReplStats {
+		states[stat.ID] = stat.State
+	}
+
+	keepIDs, keepHosts := make(map[int]bool), make(map[string]bool)
+	members := make([]ReplSetMemberConfig, 0, len(snapshot.Members))
+	for _, member := range snapshot.Members {
+		if f.Match(member, states[member.ID]) {
+			members = append(members, member)
+			keepIDs[member.ID], keepHosts[member.Host] = true, true
+		}
+	}
+	snapshot.Members = members
+
+	stats := make([]ReplMemberStat, 0, len(snapshot.ReplStats))
+	for _, stat := range snapshot.ReplStats {
+		if keepIDs[stat.ID] {
+			stats = append(stats, stat)
+		}
+	}
+	snapshot.ReplStats = stats
+
+	hosts := make([]HostInfo, 0, len(snapshot.Hosts))
+	for _, host := range snapshot.Hosts {
+		if keepHosts[host.Host] {
+			hosts = append(hosts, host)
+		}
+	}
+	snapshot.Hosts = hosts
+
+	certs := make([]TLSCert, 0, len(snapshot.TLSCerts))
+	for _, cert := range snapshot.TLSCerts {
+		if keepHosts[cert.Host] {
+			certs = append(certs, cert)
+		}
+	}
+	snapshot.TLSCerts = certs
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Migrations 最近一段时间内的 chunk 迁移统计（config.changelog）
+type Migrations struct {
+	Window    string `json:"window"`
+	Succeeded int64  `json:"succeeded"`
+	Failed    int64  `json:"failed"`
+}
+
+// Migrations 统计 window 时间内 config.changelog 中成功（moveChunk.commit）与失败（moveChunk.error）的 chunk 迁移次数
+func (mm *MongoManager) Migrations(ctx context.Context, window time.Duration) (*Migrations, error) {
+	changelog := mm.conn.Database("config").Collection("changelog")
+	since := time.Now().Add(-window)
+
+	succeeded, err := changelog.CountDocuments(ctx, bson.M{"what": "moveChunk.commit", "time": bson.M{"$gte": since}})
+	if err != nil {
+		return nil, err
+	}
+
+	failed, err := changelog.CountDocuments(ctx, bson.M{"what": "moveChunk.error", "time": bson.M{"$gte": since}})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrations{Window: formatWindow(window), Succeeded: succeeded, Failed: failed}, nil
+}
+
+// formatWindow 格式化时间窗口，去掉末尾为 0 的单位，如 24h0m0s 输出为 24h
+func formatWindow(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+
+	return s
+}
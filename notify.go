@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Notification 状态发生变化时发送的通知内容
+type Notification struct {
+	Name string `json:"name"`
+	Diff string `json:"diff"`
+	// Resolved 为 true 表示状态已经恢复到发生变化之前的基线
+	Resolved bool      `json:"resolved"`
+	Time     time.Time `json:"time"`
+	// RunID 发送通知的运行标识，与日志及保存的版本中的运行标识一致
+	RunID string `json:"run_id"`
+}
+
+// Notifier 通知渠道
+type Notifier interface {
+	Notify(ctx context.Context, notification Notification) error
+}
+
+// WebhookNotifier 以 JSON 格式将通知 POST 到指定的 URL
+type WebhookNotifier struct {
+	client *http.Client
+	url    string
+}
+
+// NewWebhookNotifier create a new WebhookNotifier
+func NewWebhookNotifier(client *http.Client, url string) *WebhookNotifier {
+	return &WebhookNotifier{client: client, url: url}
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, notification Notification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("webhook %s responded with status %d: %s", n.url, resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	return nil
+}
+
+// newNotifyHTTPClient 创建所有通知渠道共用的 HTTP 客户端，与 MongoDB 连接的配置相互独立
+func newNotifyHTTPClient(proxyURL, caFile string, timeout time.Duration) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if proxyURL != "" {
+		proxy, err := url.Parse(proxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid notify proxy url: %w", err)
+		}
+
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	if caFile != "" {
+		data, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("read notify ca file failed: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no valid certificate found in notify ca file %s", caFile)
+		}
+
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	return &http.Client{Transport: transport, Timeout: timeout}, nil
+}
+
+// newNotifiers 根据命令行参数创建通知渠道
+func newNotifiers() ([]Notifier, error) {
+	if notifyWebhooks == "" && notifyRoutesFile == "" {
+		return nil, nil
+	}
+
+	client, err := newNotifyHTTPClient(notifyProxy, notifyCAFile, notifyTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Notifier, 0)
+	for _, webhook := range strings.Split(notifyWebhooks, ",") {
+		if webhook = strings.TrimSpace(webhook); webhook != "" {
+			result = append(result, NewWebhookNotifier(client, webhook))
+		}
+	}
+
+	if notifyRoutesFile != "" {
+		routes, err := loadNotifyRoutes(notifyRoutesFile)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, route := range routes {
+			result = append(result, NewRoutedNotifier(client, route))
+		}
+	}
+
+	return result, nil
+}
+
+// notify 将状态变化发送到所有的通知渠道，发送失败只记录日志
+func notify(name string, diffText string) {
+	if diffText == "" {
+		return
+	}
+
+	sendNotification(Notification{Name: name, Diff: diffText, Time: time.Now()})
+}
+
+func sendNotification(notification Notification) {
+	if len(notifiers) == 0 {
+		return
+	}
+
+	notification.RunID = runID
+
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+
+	for _, n := range notifiers {
+		if err := n.Notify(ctx, notification); err != nil {
+			log.Printf("send notification failed: %v", err)
+		}
+	}
+}
+
+// RecoveryTracker 在持续运行模式下跟踪状态变化，用于在状态恢复到变化之前的基线时发送恢复通知
+type RecoveryTracker struct {
+	baseline string
+	alerting bool
+}
+
+// Observe 记录一次状态变化，返回当前状态是否已经恢复到第一次发生变化之前的基线
+func (t *RecoveryTracker) Observe(latest Diff) bool {
+	if !latest.Changed() {
+		return false
+	}
+
+	if !t.alerting {
+		t.baseline = latest.Original()
+		t.alerting = true
+		return false
+	}
+
+	if latest.Target() == t.baseline {
+		t.alerting = false
+		t.baseline = ""
+		return true
+	}
+
+	return false
+}
+
+// notifyChange 发送状态变化通知，开启 -notify-recovery 时，如果状态恢复到了基线，则发送恢复通知；已经通过 -ack 确认的变化不发送通知
+func notifyChange(tracker *RecoveryTracker, latest Diff) {
+	recovered := notifyRecovery && tracker.Observe(latest)
+	if latest.Changed() && latest.Acknowledged() {
+		log.Printf("change has been acknowledged, notification suppressed")
+		return
+	}
+
+	if recovered {
+		sendNotification(Notification{Name: diffName, Diff: display(latest.String()), Resolved: true, Time: time.Now()})
+		return
+	}
+
+	notify(diffName, display(latest.String()))
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+// NotifyRoutesConfig 通知路由配置文件（-notify-routes），按照发生变化的行的前缀将通知发送到不同的渠道
+//
+//	{
+//	  "routes": [
+//	    {"name": "security", "prefixes": ["USER", "ROLE"], "webhook": "https://security.example.com/hook"},
+//	    {"name": "ops", "prefixes": ["REPL", "SETTING", "MEMBER"], "webhook": "https://ops.example.com/hook"}
+//	  ]
+//	}
+//
+// 每个路由只接收匹配前缀的变化，通知中的 diff 只包含这些行，没有匹配的变化时不发送；
+// -notify-webhook 指定的渠道仍然接收所有的变化
+type NotifyRoutesConfig struct {
+	Routes []NotifyRoute `json:"routes"`
+}
+
+// NotifyRoute 一条通知路由
+type NotifyRoute struct {
+	Name     string   `json:"name"`
+	Prefixes []string `json:"prefixes"`
+	Webhook  string   `json:"webhook"`
+}
+
+// loadNotifyRoutes 读取并校验通知路由配置文件
+func loadNotifyRoutes(filename string) ([]NotifyRoute, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("read notify routes failed: %w", err)
+	}
+
+	var conf NotifyRoutesConfig
+	if err := json.Unmarshal(data, &conf); err != nil {
+		return nil, fmt.Errorf("invalid notify routes: %w", err)
+	}
+
+	if len(conf.Routes) == 0 {
+		return nil, fmt.Errorf("no route found in notify routes %s", filename)
+	}
+
+	for i, route := range conf.Routes {
+		name := route.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+
+		if len(route.Prefixes) == 0 {
+			return nil, fmt.Errorf("prefixes are required for notify route %s", name)
+		}
+
+		if u, err := url.Parse(route.Webhook); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, fmt.Errorf("invalid webhook for notify route %s: %s", name, route.Webhook)
+		}
+	}
+
+	return conf.Routes, nil
+}
+
+// RoutedNotifier 只将匹配前缀的变化发送到下游渠道
+type RoutedNotifier struct {
+	prefixes []string
+	notifier Notifier
+}
+
+// NewRoutedNotifier create a new RoutedNotifier
+func NewRoutedNotifier(client *http.Client, route NotifyRoute) *RoutedNotifier {
+	return &RoutedNotifier{prefixes: route.Prefixes, notifier: NewWebhookNotifier(client, route.Webhook)}
+}
+
+func (n *RoutedNotifier) Notify(ctx context.Context, notification Notification) error {
+	notification.Diff = filterLinesByPrefix(notification.Diff, n.prefixes)
+	if notification.Diff == "" {
+		return nil
+	}
+
+	return n.notifier.Notify(ctx, notification)
+}
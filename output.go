@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// supportedFormats 支持的输出格式
+var supportedFormats = map[string]bool{"text": true, "json": true, "ndjson": true, "sidebyside": true, "markdown": true, "badge": true, "diff-json": true}
+
+// diffRenderFormats 只改变 diff 输出方式的格式，快照仍然使用 text 格式
+var diffRenderFormats = map[string]bool{"sidebyside": true, "markdown": true, "badge": true, "diff-json": true}
+
+// OutputTarget 一个输出目标，Dest 为 - 或空时表示标准输出
+type OutputTarget struct {
+	Format string
+	Dest   string
+}
+
+// parseOutputTargets 解析 -output 参数，格式为 format[:dest]，多个使用逗号分隔，如 text:-,json:/tmp/snap.json
+//
+// 第一个输出目标为主输出，用于保存快照与 diff，只能输出到标准输出；其余的输出目标必须指定文件，写入本次采集的完整快照
+func parseOutputTargets(expr string) ([]OutputTarget, error) {
+	targets := make([]OutputTarget, 0)
+	for _, item := range strings.Split(expr, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		target := OutputTarget{Format: item}
+		if idx := strings.Index(item, ":"); idx >= 0 {
+			target = OutputTarget{Format: item[:idx], Dest: item[idx+1:]}
+		}
+
+		if !supportedFormats[target.Format] {
+			return nil, fmt.Errorf("unsupported output format: %s", target.Format)
+		}
+
+		if len(targets) == 0 && target.Dest != "" && target.Dest != "-" {
+			return nil, fmt.Errorf("the first output %s is used for diff and must be written to stdout", item)
+		}
+
+		if len(targets) > 0 && diffRenderFormats[target.Format] {
+			return nil, fmt.Errorf("%s only changes how the diff is rendered and must be the first output", target.Format)
+		}
+
+		if len(targets) > 0 && (target.Dest == "" || target.Dest == "-") {
+			return nil, fmt.Errorf("additional output %s requires a file destination", item)
+		}
+
+		targets = append(targets, target)
+	}
+
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no output format given")
+	}
+
+	return targets, nil
+}
+
+// writeSnapshot 按照主输出格式输出快照
+func writeSnapshot(out io.Writer, snapshot *Snapshot) error {
+	return renderSnapshot(out, snapshot, outputFormat)
+}
+
+// writeExtraOutputs 将快照按照附加的输出目标写入文件
+func writeExtraOutputs(snapshot *Snapshot) error {
+	for _, target := range outputTargets[1:] {
+		buffer := bytes.NewBuffer(nil)
+		if err := renderSnapshot(buffer, snapshot, target.Format); err != nil {
+			return err
+		}
+
+		if err := ioutil.WriteFile(target.Dest, buffer.Bytes(), 0644); err != nil {
+			return fmt.Errorf("write %s output to %s failed: %w", target.Format, target.Dest, err)
+		}
+	}
+
+	return nil
+}
+
+func renderSnapshot(out io.Writer, snapshot *Snapshot, format string) error {
+	switch format {
+	case "json":
+		return writeJSON(out, snapshot)
+	case "ndjson":
+		return writeNDJSON(out, snapshot)
+	default:
+		// sidebyside、markdown、badge、diff-json 只影响 diff 的输出方式，快照与 text 格式相同
+		return writeText(out, snapshot)
+	}
+}
+
+// defaultTextTemplate 默认的文本输出格式，可以通过 -template 参数替换
+const defaultTextTemplate = `SUMMARY: databases={{.Summary.Databases}}, users={{.Summary.Users}}, roles={{.Summary.Roles}}, members={{.Summary.Members}}
+{{if .DatabaseSizes -}}
+{{range .DatabaseSizes -}}
+DB: name={{.Name}}, sizeOnDiskMB={{.SizeOnDiskMB}}, empty={{.Empty}}
+{{end -}}
+{{else -}}
+{{range .Databases -}}
+DB: {{.}}
+{{end -}}
+{{end -}}
+{{range $user := .Users -}}
+USER: db={{.DB}}, user={{.User}}
+{{range .Roles -}}
+USER_ROLE: db={{$user.DB}}, user={{$user.User}}, role={{.DB}}/{{.Role}}
+{{end -}}
+{{end -}}
+{{range $role := .Roles -}}
+ROLE: db={{.DB}}, role={{.Role}}
+{{range .Roles -}}
+ROLE_INHERITS: db={{$role.DB}}, role={{$role.Role}}, inheritsDb={{.DB}}, inheritsRole={{.Role}}
+{{end -}}
+{{end -}}
+{{range .DBStats -}}
+DBSTATS: db={{.DB}}, collections={{.Collections}}, dataSizeMB={{.DataSizeMB}}, indexes={{.Indexes}}
+{{end -}}
+{{range .CollCounts -}}
+DBCOLLCOUNT: db={{.DB}}, collections={{.Collections}}
+{{end -}}
+{{range .Indexes -}}
+INDEX: db={{.DB}}, coll={{.Coll}}, name={{.Name}}, key={{.Key}}, unique={{.Unique}}, sparse={{.Sparse}}{{with .TTL}}, ttl={{.}}{{end}}{{if .Hidden}}, hidden=true{{end}}{{if .PrepareUnique}}, prepareUnique=true{{end}}{{with .Partial}}, partial={{.}}{{end}}
+{{end -}}
+{{range .IndexSizes -}}
+INDEX_SIZE: db={{.DB}}, coll={{.Coll}}, name={{.Name}}, sizeMB={{.SizeMB}}
+{{end -}}
+{{range .DupIndexes -}}
+DUP_INDEX: db={{.DB}}, coll={{.Coll}}, names={{join .Names ","}}
+{{end -}}
+{{range .Members -}}
+SETTING: id={{.ID}}, host={{.Host}}, vote={{.Votes}}, arbiterOnly={{.ArbiterOnly}}, buildIndexes={{.BuildIndexes}}, hidden={{.Hidden}}, priority={{.Priority}}
+{{end -}}
+{{range .NoElect -}}
+NO_ELECT_MEMBER: id={{.ID}}, host={{.Host}}
+{{end -}}
+{{range $member := .Members -}}
+{{range $key, $value := .Tags -}}
+MEMBER_TAG: id={{$member.ID}}, key={{$key}}, value={{$value}}
+{{end -}}
+{{end -}}
+{{range .WriteConcernModes -}}
+WRITE_CONCERN_MODE: name={{.Name}}, def={{.Def}}
+{{end -}}
+{{range .ReplSettings -}}
+REPL_SETTING: key={{.Key}}, value={{.Value}}
+{{end -}}
+{{range .ReplStats -}}
+REPL_STAT: id={{.ID}}, name={{.Name}}, state={{.State}}, health={{.Health}}, syncSourceHost={{.SyncSourceHost}}, syncingTo={{.SyncingTo}}
+{{end -}}
+{{with .Elections -}}
+ELECTION: called={{.Called}}, won={{.Won}}, numCatchUps={{.NumCatchUps}}, avgCatchUpOps={{.AverageCatchUpOps}}, delta={{.Delta}}
+{{end -}}
+{{range .Unhealthy -}}
+MEMBER_UNHEALTHY: name={{.Name}}, state={{.State}}
+{{end -}}
+{{range .TLSCerts -}}
+TLSCERT: host={{.Host}}, expires={{.Expires}}, daysLeft={{.DaysLeft}}
+{{end -}}
+{{range .Hosts -}}
+HOST: host={{.Host}}, numCores={{.NumCores}}, memSizeMB={{.MemSizeMB}}, cpuArch={{.CPUArch}}, osType={{.OSType}}, osName={{.OSName}}, osVersion={{.OSVersion}}
+{{end -}}
+{{range .Mongos -}}
+MONGOS: host={{.Host}}, version={{.MongoVersion}}
+{{end -}}
+{{range .Chunks -}}
+CHUNKS: ns={{.NS}}, shard={{.Shard}}, count={{.Count}}
+{{end -}}
+{{range .Zones -}}
+ZONE: ns={{.NS}}, zone={{.Zone}}, min={{.Min}}, max={{.Max}}
+{{end -}}
+{{with .Migrations -}}
+MIGRATIONS: last{{.Window}}={{.Succeeded}}, failed={{.Failed}}
+{{end -}}
+{{with .Hello -}}
+HELLO: primary={{.Primary}}, me={{.Me}}, setName={{.SetName}}, minWireVersion={{.MinWireVersion}}, maxWireVersion={{.MaxWireVersion}}
+{{end -}}
+{{with .TopologyVersion -}}
+TOPOLOGY_VERSION: processId={{.ProcessID}}, counter={{.Counter}}
+{{end -}}
+{{with .Storage -}}
+STORAGE: engine={{.Engine}}, journalEnabled={{optionalBool .JournalEnabled}}, directoryPerDB={{optionalBool .DirectoryPerDB}}, persistent={{.Persistent}}, readOnly={{.ReadOnly}}
+{{end -}}
+{{with .Checkpoint -}}
+CHECKPOINT: intervalSecs={{.IntervalSecs}}, journalCommitIntervalMs={{.JournalCommitIntervalMs}}
+{{end -}}
+{{with .CurrentOp -}}
+CURRENTOP: {{.}}
+{{end -}}
+{{with .Transactions -}}
+TXN: lifetimeLimitSecs={{.LifetimeLimitSecs}}, maxLockRequestTimeoutMs={{.MaxLockRequestTimeoutMs}}
+{{end -}}
+{{with .Scripting -}}
+SCRIPTING: javascriptEnabled={{optionalBool .JavascriptEnabled}}
+{{end -}}
+{{with .FreeMonitoring -}}
+FREEMON: state={{.State}}
+{{end -}}
+{{range .AuthProviders -}}
+AUTHPROVIDER: provider={{.Provider}}, key={{.Key}}, value={{.Value}}
+{{end -}}
+{{range .CmdLine -}}
+CMDLINE: key={{.Key}}, value={{.Value}}
+{{end -}}
+{{range .Parameters -}}
+PARAM: name={{.Name}}, value={{.Value}}
+{{end -}}
+{{range .Commands -}}
+COMMAND_AVAILABLE: name={{.Name}}, available={{.Available}}
+{{end -}}
+{{range .ClusterParams -}}
+CLUSTERPARAM: name={{.Name}}, value={{.Value}}
+{{end -}}
+{{with .ChangeStream -}}
+CHANGESTREAM: preImageRetention={{.PreImageRetention}}
+{{end -}}
+{{with .OplogSize -}}
+OPLOG_SIZE: maxMB={{.MaxMB}}
+{{end -}}
+{{range .Aggregations -}}
+AGG[{{.Label}}]: {{.Doc}}
+{{end -}}
+{{range .Custom -}}
+{{.Line}}
+{{end -}}
+{{if .Errors -}}
+ERRORS: count={{len .Errors}}
+{{range .Errors -}}
+ERROR: collector={{.Collector}}, error={{.Error}}
+{{end -}}
+{{end -}}
+`
+
+// templateFuncs 输出模板中可以使用的函数
+var templateFuncs = template.FuncMap{
+	"optionalBool": optionalBool,
+	"join":         strings.Join,
+}
+
+// parseTextTemplate 解析 -template 参数，以 @ 开头时从文件中读取模板，为空时使用默认模板
+func parseTextTemplate(tmpl string) (*template.Template, error) {
+	if tmpl == "" {
+		tmpl = defaultTextTemplate
+	} else if strings.HasPrefix(tmpl, "@") {
+		data, err := ioutil.ReadFile(tmpl[1:])
+		if err != nil {
+			return nil, fmt.Errorf("read template file failed: %w", err)
+		}
+
+		tmpl = string(data)
+	}
+
+	t, err := template.New("text").Funcs(templateFuncs).Parse(tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+
+	return t, nil
+}
+
+func writeText(out io.Writer, snapshot *Snapshot) error {
+	return textTemplate.Execute(out, snapshot)
+}
+
+// parseIndent 解析 -indent 参数，值为空格数量，tab 表示使用制表符
+func parseIndent(expr string) (string, error) {
+	if expr == "tab" {
+		return "\t", nil
+	}
+
+	n, err := strconv.Atoi(expr)
+	if err != nil || n < 0 {
+		return "", fmt.Errorf("invalid -indent value %q: must be a non-negative number or tab", expr)
+	}
+
+	return strings.Repeat(" ", n), nil
+}
+
+func writeJSON(out io.Writer, snapshot *Snapshot) error {
+	var data interface{} = snapshot
+	if len(selectPaths) > 0 {
+		selected, err := SelectJSON(snapshot, selectPaths)
+		if err != nil {
+			return err
+		}
+
+		data = selected
+	}
+
+	buffer := bytes.NewBuffer(nil)
+	encoder := json.NewEncoder(buffer)
+	encoder.SetIndent("", jsonIndent)
+	if err := encoder.Encode(data); err != nil {
+		return err
+	}
+
+	_, err := out.Write(applyJSONCase(buffer.Bytes()))
+	return err
+}
+
+// ndjsonRecord 单行 JSON 输出的内容，在快照的基础上增加 diff 名称、采集时间与运行标识，方便日志系统检索
+type ndjsonRecord struct {
+	Name  string    `json:"name"`
+	Time  time.Time `json:"time"`
+	RunID string    `json:"run_id"`
+	*Snapshot
+}
+
+// writeNDJSON 将完整的快照输出为一行紧凑的 JSON，适用于按行采集的日志系统
+func writeNDJSON(out io.Writer, snapshot *Snapshot) error {
+	data, err := json.Marshal(ndjsonRecord{Name: diffName, Time: time.Now(), RunID: runID, Snapshot: snapshot})
+	if err != nil {
+		return err
+	}
+
+	_, err = out.Write(append(applyJSONCase(data), '\n'))
+	return err
+}
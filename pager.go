@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+)
+
+// pagerCommand 返回分页程序，-pager 优先，未指定时使用 PAGER 环境变量
+func pagerCommand() string {
+	if pager != "" {
+		return pager
+	}
+
+	return os.Getenv("PAGER")
+}
+
+// isTerminal 判断 f 是否为终端
+func isTerminal(f *os.File) bool {
+	stat, err := f.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
+
+// pagedStdout 返回用于输出 diff 的 Writer 以及输出完成后需要调用的 flush 函数，
+// 配置了分页程序且标准输出为终端时，内容先缓存起来，flush 时通过分页程序输出，否则直接写入标准输出
+func pagedStdout() (io.Writer, func()) {
+	command := pagerCommand()
+	if command == "" || !isTerminal(os.Stdout) {
+		return os.Stdout, func() {}
+	}
+
+	var buffer bytes.Buffer
+	return &buffer, func() {
+		if buffer.Len() == 0 {
+			return
+		}
+
+		cmd := exec.Command("sh", "-c", command)
+		cmd.Stdin = bytes.NewReader(buffer.Bytes())
+		cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+		if err := cmd.Run(); err != nil {
+			log.Printf("run pager %q failed: %v", command, err)
+			_, _ = os.Stdout.Write(buffer.Bytes())
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// parameterPresets 预设的服务端参数集合，通过 -preset 使用，避免手动列出所有关心的参数
+var parameterPresets = map[string][]string{
+	// capacity 连接池、并发与内存相关的容量参数
+	"capacity": {
+		"connPoolMaxConnsPerHost",
+		"connPoolMaxShardedConnsPerHost",
+		"taskExecutorPoolSize",
+		"ShardingTaskExecutorPoolMaxSize",
+		"ShardingTaskExecutorPoolMinSize",
+		"wiredTigerConcurrentReadTransactions",
+		"wiredTigerConcurrentWriteTransactions",
+		"maxIndexBuildMemoryUsageMegabytes",
+		"internalQueryMaxBlockingSortMemoryUsageBytes",
+		"transactionLifetimeLimitSeconds",
+	},
+	// security 认证、审计与日志脱敏相关的安全参数
+	"security": {
+		"authenticationMechanisms",
+		"enableLocalhostAuthBypass",
+		"scramIterationCount",
+		"scramSHA256IterationCount",
+		"auditAuthorizationSuccess",
+		"redactClientLogData",
+		"tlsWithholdClientCertificate",
+		"allowRolesFromX509Certificates",
+	},
+	// durability 持久化与复制相关的参数
+	"durability": {
+		"journalCommitInterval",
+		"syncdelay",
+		"enableFlowControl",
+		"flowControlTargetLagSeconds",
+		"replWriterThreadCount",
+		"writePeriodicNoops",
+		"oplogMinRetentionHours",
+	},
+}
+
+// Parameter 一个服务端参数（getParameter）
+type Parameter struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// parseParameterNames 合并 -parameters 与 -preset 中的参数名称，去重后按照名称排序
+func parseParameterNames(names, presets string) ([]string, error) {
+	selected := make(map[string]bool)
+	for _, name := range strings.Split(names, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			selected[name] = true
+		}
+	}
+
+	for _, preset := range strings.Split(presets, ",") {
+		if preset = strings.TrimSpace(preset); preset == "" {
+			continue
+		}
+
+		params, ok := parameterPresets[preset]
+		if !ok {
+			return nil, fmt.Errorf("unknown preset %q, supported presets: capacity, durability, security", preset)
+		}
+
+		for _, name := range params {
+			selected[name] = true
+		}
+	}
+
+	result := make([]string, 0, len(selected))
+	for name := range selected {
+		result = append(result, name)
+	}
+
+	sort.Strings(result)
+	return result, nil
+}
+
+// Parameters 返回 names 中的服务端参数，服务端不存在的参数（如版本不支持）不会出现在结果中
+func (mm *MongoManager) Parameters(ctx context.Context, names []string) ([]Parameter, error) {
+	var all bson.M
+	if err := mm.conn.Database("admin").RunCommand(ctx, bson.M{"getParameter": "*"}).Decode(&all); err != nil {
+		return nil, err
+	}
+
+	params := make([]Parameter, 0, len(names))
+	for _, name := range names {
+		if value, ok := all[name]; ok {
+			params = append(params, Parameter{Name: name, Value: formatSettingValue(name, value)})
+		}
+	}
+
+	return params, nil
+}
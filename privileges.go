@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type ConnectionStatusResp struct {
+	AuthInfo ConnectionAuthInfo `bson:"authInfo"`
+}
+
+type ConnectionAuthInfo struct {
+	AuthenticatedUsers     []ConnectionUser `bson:"authenticatedUsers"`
+	AuthenticatedUserRoles []Role           `bson:"authenticatedUserRoles"`
+}
+
+type ConnectionUser struct {
+	User string `bson:"user"`
+	DB   string `bson:"db"`
+}
+
+// requiredPrivilege 采集器正常工作所需的内置角色，满足 grantedBy 中任意一个角色即可
+type requiredPrivilege struct {
+	role       string
+	grantedBy  []string
+	collectors []string
+}
+
+var requiredPrivileges = []requiredPrivilege{
+	{
+		role:       "clusterMonitor",
+		grantedBy:  []string{"clusterMonitor", "clusterAdmin", "root", "__system"},
+		collectors: []string{"repl_config", "repl_status", "oplog_size", "storage", "host_info"},
+	},
+	{
+		role:       "readAnyDatabase",
+		grantedBy:  []string{"readAnyDatabase", "readWriteAnyDatabase", "root", "__system"},
+		collectors: []string{"databases", "mongos"},
+	},
+	{
+		role:       "userAdminAnyDatabase",
+		grantedBy:  []string{"userAdminAnyDatabase", "root", "__system"},
+		collectors: []string{"users", "roles"},
+	},
+}
+
+func (mm *MongoManager) ConnectionStatus(ctx context.Context) (ConnectionStatusResp, error) {
+	var resp ConnectionStatusResp
+	if err := mm.conn.Database("admin").RunCommand(ctx, bson.M{"connectionStatus": 1}).Decode(&resp); err != nil {
+		return ConnectionStatusResp{}, err
+	}
+
+	return resp, nil
+}
+
+// checkPrivileges 检查当前用户是否拥有采集所需的角色，缺少时输出警告以及会受到影响的采集器
+// strict 为 true 时，缺少任意角色都会返回错误
+//
+// 这里只检查 admin 数据库中的内置角色，拥有等价权限的自定义角色同样会产生警告
+func checkPrivileges(ctx context.Context, mm *MongoManager, strict bool) error {
+	status, err := mm.ConnectionStatus(ctx)
+	if err != nil {
+		return err
+	}
+
+	// 没有已认证的用户，说明服务端没有开启认证，无需检查
+	if len(status.AuthInfo.AuthenticatedUsers) == 0 {
+		return nil
+	}
+
+	granted := make(map[string]bool)
+	for _, role := range status.AuthInfo.AuthenticatedUserRoles {
+		if role.DB == "admin" {
+			granted[role.Role] = true
+		}
+	}
+
+	missing := make([]string, 0)
+	for _, req := range requiredPrivileges {
+		satisfied := false
+		for _, role := range req.grantedBy {
+			if granted[role] {
+				satisfied = true
+				break
+			}
+		}
+
+		if !satisfied {
+			missing = append(missing, req.role)
+			log.Printf("WARNING: current user lacks %s (or equivalent) role, these collectors will be degraded: %s", req.role, strings.Join(req.collectors, ", "))
+		}
+	}
+
+	if strict && len(missing) > 0 {
+		return MissingPrivilegesError{Roles: missing}
+	}
+
+	return nil
+}
+
+// MissingPrivilegesError 开启 -strict 时当前用户缺少采集所需的角色
+type MissingPrivilegesError struct {
+	Roles []string
+}
+
+func (e MissingPrivilegesError) Error() string {
+	return fmt.Sprintf("current user lacks required roles: %s", strings.Join(e.Roles, ", "))
+}
+
+// privilegesCheck 记录权限检查的结果，当前用户的角色在进程运行期间不会变化，watch 与 serve 模式下不需要每次采集都重复检查
+var privilegesCheck struct {
+	sync.Mutex
+	done bool
+	err  error
+}
+
+// checkPrivilegesOnce 每个进程只执行一次 checkPrivileges，之后直接返回第一次的结果；
+// 获取连接状态失败时不记录结果，下一次采集时重新检查
+func checkPrivilegesOnce(ctx context.Context, mm *MongoManager, strict bool) error {
+	privilegesCheck.Lock()
+	defer privilegesCheck.Unlock()
+
+	if privilegesCheck.done {
+		return privilegesCheck.err
+	}
+
+	err := checkPrivileges(ctx, mm, strict)
+	var missingErr MissingPrivilegesError
+	if err == nil || errors.As(err, &missingErr) {
+		privilegesCheck.done, privilegesCheck.err = true, err
+	}
+
+	return err
+}
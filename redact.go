@@ -0,0 +1,147 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// uriCredentialRegexp 匹配 mongodb:// 与 mongodb+srv:// URI 中 用户名:密码@ 部分的密码
+var uriCredentialRegexp = regexp.MustCompile(`(mongodb(?:\+srv)?://[^:/@\s]*:)([^@/\s]*)(@)`)
+
+// redactURI 将文本中所有 MongoDB URI 的密码替换为 ***，错误信息、日志等可能包含 URI 的内容在输出前都需要经过该函数处理
+func redactURI(text string) string {
+	return uriCredentialRegexp.ReplaceAllString(text, "${1}***${3}")
+}
+
+// redactWriter 写入前隐藏 MongoDB URI 中的密码，用于日志输出
+type redactWriter struct {
+	w io.Writer
+}
+
+func (r redactWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(r.w, redactURI(string(p))); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// secretKeyPatterns 配置路径（忽略大小写）中包含这些内容时视为敏感配置，如 security.keyFile、net.tls.certificateKeyFilePassword
+var secretKeyPatterns = []string{"password", "secret", "token", "keyfile", "clusterfile", "privatekey", "credential", "apikey"}
+
+// isSecretKey 判断配置路径是否为敏感配置
+func isSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, pattern := range secretKeyPatterns {
+		if strings.Contains(key, pattern) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// redactionKeyFile data-dir 中保存 HMAC 密钥的文件，与历史版本放在一起，同一个 data-dir 中相同的配置值始终得到相同的结果
+const redactionKeyFile = ".redaction-key"
+
+var redactionKey struct {
+	sync.Mutex
+	key []byte
+}
+
+// loadRedactionKey 读取 data-dir 中的 HMAC 密钥，不存在时生成随机密钥并以 0600 权限保存
+func loadRedactionKey(dataDir string) error {
+	filename := filepath.Join(dataDir, redactionKeyFile)
+	key, err := readRedactionKey(filename)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+
+		if key, err = createRedactionKey(filename); err != nil {
+			return err
+		}
+	}
+
+	redactionKey.Lock()
+	defer redactionKey.Unlock()
+	redactionKey.key = key
+
+	return nil
+}
+
+func readRedactionKey(filename string) ([]byte, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) < 32 {
+		return nil, fmt.Errorf("invalid redaction key in %s, remove it to generate a new one", filename)
+	}
+
+	return key, nil
+}
+
+// createRedactionKey 生成并保存新的密钥，多个进程（如 -batch）同时创建时以先创建的为准
+func createRedactionKey(filename string) ([]byte, error) {
+	key, err := randomRedactionKey()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if os.IsExist(err) {
+		return readRedactionKey(filename)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("save redaction key failed: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(hex.EncodeToString(key)); err != nil {
+		return nil, fmt.Errorf("save redaction key failed: %w", err)
+	}
+
+	return key, nil
+}
+
+func randomRedactionKey() ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("generate redaction key failed: %w", err)
+	}
+
+	return key, nil
+}
+
+// redactSecret 将敏感配置的值替换为固定的 <redacted:...> 标记，标记中包含使用 data-dir 中密钥计算的 HMAC，
+// 只用于发现配置发生了变化；没有加载密钥时（如 -check）使用进程内的随机密钥，结果在不同进程之间不可比较
+func redactSecret(value string) string {
+	redactionKey.Lock()
+	if redactionKey.key == nil {
+		key, err := randomRedactionKey()
+		if err != nil {
+			redactionKey.Unlock()
+			return "<redacted>"
+		}
+
+		redactionKey.key = key
+	}
+	mac := hmac.New(sha256.New, redactionKey.key)
+	redactionKey.Unlock()
+
+	_, _ = mac.Write([]byte(value))
+	return "<redacted:" + hex.EncodeToString(mac.Sum(nil))[:16] + ">"
+}
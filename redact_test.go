@@ -0,0 +1,59 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestFormatSettingValueRedactsSecrets(t *testing.T) {
+	dataDir, err := ioutil.TempDir("", "mongo-diff-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dataDir)
+
+	if err := loadRedactionKey(dataDir); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		key    string
+		secret bool
+	}{
+		{key: "bind.queryPassword", secret: true},
+		{key: "security.keyFile", secret: true},
+		{key: "net.tls.certificateKeyFile", secret: true},
+		{key: "net.tls.clusterFile", secret: true},
+		{key: "security.kmip.clientCertificatePassword", secret: true},
+		{key: "oidc.clientSecret", secret: true},
+		{key: "vault.authToken", secret: true},
+		{key: "security.clusterAuthMode"},
+		{key: "net.bindIp"},
+	}
+
+	for _, c := range cases {
+		got := formatSettingValue(c.key, "s3cret")
+		if redacted := strings.HasPrefix(got, "<redacted:") && !strings.Contains(got, "s3cret"); redacted != c.secret {
+			t.Errorf("formatSettingValue(%q) = %q, want redacted %t", c.key, got, c.secret)
+		}
+	}
+
+	// 相同的值得到相同的结果，不同的值得到不同的结果
+	if formatSettingValue("password", "a") != formatSettingValue("password", "a") {
+		t.Errorf("expect redacted value to be stable")
+	}
+	if formatSettingValue("password", "a") == formatSettingValue("password", "b") {
+		t.Errorf("expect redacted value to change with the secret")
+	}
+
+	// 再次加载时使用已经保存的密钥
+	before := formatSettingValue("password", "a")
+	if err := loadRedactionKey(dataDir); err != nil {
+		t.Fatal(err)
+	}
+	if after := formatSettingValue("password", "a"); after != before {
+		t.Errorf("expect reloaded key to give the same result, got %q and %q", before, after)
+	}
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// runID 本次运行的唯一标识，包含在日志、通知、post-hook 环境变量与保存的版本中，用于关联同一次运行的所有产出
+var runID = newRunID()
+
+// newRunID 生成一个随机的 UUID（v4）
+func newRunID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Errorf("generate run id failed: %w", err))
+	}
+
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
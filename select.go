@@ -0,0 +1,365 @@
+package main
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// SelectPath 一条解析后的 JSONPath 风格表达式
+type SelectPath struct {
+	expr     string
+	segments []selectSegment
+}
+
+type selectSegment struct {
+	// key 为对象的字段名，wildcard 为 true 时表示匹配所有字段或数组元素
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// ParseSelectPaths 解析使用逗号分隔的多个表达式，支持 $.a.b、$.a[*]、$.a[0]、$.a.*、$['a'] 语法
+func ParseSelectPaths(exprs string) ([]SelectPath, error) {
+	paths := make([]SelectPath, 0)
+	for _, expr := range strings.Split(exprs, ",") {
+		expr = strings.TrimSpace(expr)
+		if expr == "" {
+			continue
+		}
+
+		path, err := ParseSelectPath(expr)
+		if err != nil {
+			return nil, err
+		}
+
+		paths = append(paths, path)
+	}
+
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("invalid select expression %q: no path given", exprs)
+	}
+
+	return paths, nil
+}
+
+// ParseSelectPath 解析单条表达式
+func ParseSelectPath(expr string) (SelectPath, error) {
+	invalid := func(pos int, reason string) (SelectPath, error) {
+		return SelectPath{}, fmt.Errorf("invalid select expression %q at position %d: %s", expr, pos, reason)
+	}
+
+	if !strings.HasPrefix(expr, "$") {
+		return invalid(0, "must start with $")
+	}
+
+	path := SelectPath{expr: expr}
+	for i := 1; i < len(expr); {
+		switch expr[i] {
+		case '.':
+			i++
+			start := i
+			for i < len(expr) && expr[i] != '.' && expr[i] != '[' {
+				i++
+			}
+
+			name := expr[start:i]
+			if name == "" {
+				return invalid(start, "field name expected")
+			}
+
+			if name == "*" {
+				path.segments = append(path.segments, selectSegment{wildcard: true})
+			} else {
+				path.segments = append(path.segments, selectSegment{key: name})
+			}
+		case '[':
+			end := strings.IndexByte(expr[i:], ']')
+			if end < 0 {
+				return invalid(i, "missing ]")
+			}
+
+			inner := expr[i+1 : i+end]
+			switch {
+			case inner == "*":
+				path.segments = append(path.segments, selectSegment{wildcard: true})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				path.segments = append(path.segments, selectSegment{key: inner[1 : len(inner)-1]})
+			default:
+				index, err := strconv.Atoi(inner)
+				if err != nil || index < 0 {
+					return invalid(i+1, fmt.Sprintf("invalid index %q", inner))
+				}
+
+				path.segments = append(path.segments, selectSegment{index: index, isIndex: true})
+			}
+
+			i += end + 1
+		default:
+			return invalid(i, fmt.Sprintf("unexpected character %q", expr[i]))
+		}
+	}
+
+	return path, nil
+}
+
+// String 返回原始表达式
+func (p SelectPath) String() string {
+	return p.expr
+}
+
+func (s selectSegment) String() string {
+	switch {
+	case s.wildcard:
+		return "*"
+	case s.isIndex:
+		return fmt.Sprintf("[%d]", s.index)
+	}
+
+	return s.key
+}
+
+// ValidateSelectPaths 根据 v 的类型（JSON 字段名）检查每条表达式，不可能选中任何字段的表达式（如字段名拼写错误）返回错误，
+// 只检查结构，不关心 v 中是否有数据
+func ValidateSelectPaths(v interface{}, paths []SelectPath) error {
+	for _, path := range paths {
+		if seg, ok := validateSegments(reflect.TypeOf(v), path.segments); !ok {
+			return fmt.Errorf("invalid select expression %q: %s does not match any field", path.expr, seg)
+		}
+	}
+
+	return nil
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// validateSegments 检查 segments 能否在类型 t 中选中字段，不能选中时同时返回第一个无法匹配的部分
+func validateSegments(t reflect.Type, segments []selectSegment) (selectSegment, bool) {
+	if len(segments) == 0 {
+		return selectSegment{}, true
+	}
+
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	seg, rest := segments[0], segments[1:]
+
+	// 自定义 JSON 编码的类型无法根据类型推断结构
+	if t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonMarshalerType) {
+		return selectSegment{}, true
+	}
+
+	if t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType) {
+		return seg, false
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		return selectSegment{}, true
+	case reflect.Map:
+		if seg.isIndex {
+			return seg, false
+		}
+
+		return validateSegments(t.Elem(), rest)
+	case reflect.Slice, reflect.Array:
+		if !seg.wildcard && !seg.isIndex {
+			return seg, false
+		}
+
+		return validateSegments(t.Elem(), rest)
+	case reflect.Struct:
+		if seg.isIndex {
+			return seg, false
+		}
+
+		fields := jsonFields(t)
+		if !seg.wildcard {
+			field, ok := fields[seg.key]
+			if !ok {
+				return seg, false
+			}
+
+			return validateSegments(field, rest)
+		}
+
+		failed := seg
+		for _, field := range fields {
+			var ok bool
+			if failed, ok = validateSegments(field, rest); ok {
+				return selectSegment{}, true
+			}
+		}
+
+		return failed, false
+	}
+
+	return seg, false
+}
+
+// jsonFields 返回结构体编码为 JSON 之后的字段名与字段类型，包括嵌入结构体中的字段
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" || (field.PkgPath != "" && !field.Anonymous) {
+			continue
+		}
+
+		name := strings.Split(tag, ",")[0]
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+
+			if embedded.Kind() == reflect.Struct {
+				for k, v := range jsonFields(embedded) {
+					if _, ok := fields[k]; !ok {
+						fields[k] = v
+					}
+				}
+				continue
+			}
+		}
+
+		if field.PkgPath != "" {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		fields[name] = field.Type
+	}
+
+	return fields
+}
+
+// selection 记录被选中的节点，all 为 true 表示整个节点都被选中
+type selection struct {
+	all      bool
+	children map[string]*selection
+}
+
+func (s *selection) child(key string) *selection {
+	if s.children == nil {
+		s.children = make(map[string]*selection)
+	}
+
+	if _, ok := s.children[key]; !ok {
+		s.children[key] = &selection{}
+	}
+
+	return s.children[key]
+}
+
+// SelectJSON 将 data 转换为 JSON 结构后，只保留 paths 选中的部分，保留原有的层级结构，
+// 表达式不可能选中 data 类型中的任何字段时返回错误
+func SelectJSON(data interface{}, paths []SelectPath) (interface{}, error) {
+	if err := ValidateSelectPaths(data, paths); err != nil {
+		return nil, err
+	}
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(encoded, &doc); err != nil {
+		return nil, err
+	}
+
+	root := &selection{}
+	for _, path := range paths {
+		markSelection(doc, path.segments, root)
+	}
+
+	return buildSelection(doc, root), nil
+}
+
+func markSelection(value interface{}, segments []selectSegment, sel *selection) bool {
+	if len(segments) == 0 {
+		sel.all = true
+		return true
+	}
+
+	seg, rest := segments[0], segments[1:]
+	matched := false
+
+	switch val := value.(type) {
+	case map[string]interface{}:
+		if seg.isIndex {
+			return false
+		}
+
+		for key, child := range val {
+			if !seg.wildcard && key != seg.key {
+				continue
+			}
+
+			if markSelection(child, rest, sel.child(key)) {
+				matched = true
+			} else if !sel.children[key].all && len(sel.children[key].children) == 0 {
+				delete(sel.children, key)
+			}
+		}
+	case []interface{}:
+		if !seg.wildcard && !seg.isIndex {
+			return false
+		}
+
+		for i, child := range val {
+			if seg.isIndex && i != seg.index {
+				continue
+			}
+
+			key := strconv.Itoa(i)
+			if markSelection(child, rest, sel.child(key)) {
+				matched = true
+			} else if !sel.children[key].all && len(sel.children[key].children) == 0 {
+				delete(sel.children, key)
+			}
+		}
+	}
+
+	return matched
+}
+
+func buildSelection(value interface{}, sel *selection) interface{} {
+	if sel.all {
+		return value
+	}
+
+	switch val := value.(type) {
+	case map[string]interface{}:
+		res := make(map[string]interface{})
+		for key, child := range sel.children {
+			res[key] = buildSelection(val[key], child)
+		}
+
+		return res
+	case []interface{}:
+		res := make([]interface{}, 0)
+		for i, item := range val {
+			if child, ok := sel.children[strconv.Itoa(i)]; ok {
+				res = append(res, buildSelection(item, child))
+			}
+		}
+
+		return res
+	}
+
+	return nil
+}
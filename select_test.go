@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseSelectPaths(t *testing.T) {
+	cases := []struct {
+		expr    string
+		wantErr bool
+	}{
+		{expr: "$.users[*].user"},
+		{expr: "$.members[0], $['version']"},
+		{expr: "$.repl_status.*"},
+		{expr: "users", wantErr: true},
+		{expr: "$.users[", wantErr: true},
+		{expr: "$.users[-1]", wantErr: true},
+		{expr: "$..users", wantErr: true},
+		{expr: " , ", wantErr: true},
+	}
+
+	for _, c := range cases {
+		if _, err := ParseSelectPaths(c.expr); (err != nil) != c.wantErr {
+			t.Errorf("ParseSelectPaths(%q) error = %v, wantErr %t", c.expr, err, c.wantErr)
+		}
+	}
+}
+
+func TestValidateSelectPaths(t *testing.T) {
+	cases := []struct {
+		expr    string
+		wantErr bool
+	}{
+		{expr: "$.users"},
+		{expr: "$.users[*].user"},
+		{expr: "$.users[0].roles[*].role"},
+		{expr: "$['databases']"},
+		{expr: "$.*"},
+		{expr: "$.user[*]", wantErr: true},
+		{expr: "$.users[*].usr", wantErr: true},
+		{expr: "$.users.user", wantErr: true},
+		{expr: "$.databases.name", wantErr: true},
+		{expr: "$.databases[0].name", wantErr: true},
+		{expr: "$[0]", wantErr: true},
+	}
+
+	for _, c := range cases {
+		paths, err := ParseSelectPaths(c.expr)
+		if err != nil {
+			t.Fatalf("ParseSelectPaths(%q) failed: %v", c.expr, err)
+		}
+
+		if err := ValidateSelectPaths(&Snapshot{}, paths); (err != nil) != c.wantErr {
+			t.Errorf("ValidateSelectPaths(%q) error = %v, wantErr %t", c.expr, err, c.wantErr)
+		}
+	}
+}
+
+func TestSelectJSON(t *testing.T) {
+	snapshot := &Snapshot{
+		Databases: []string{"admin", "app"},
+		Users:     []User{{User: "admin", DB: "admin"}, {User: "app", DB: "app"}},
+	}
+
+	cases := []struct {
+		expr    string
+		want    string
+		wantErr bool
+	}{
+		{expr: "$.databases", want: `{"databases":["admin","app"]}`},
+		{expr: "$.users[1].user", want: `{"users":[{"user":"app"}]}`},
+		{expr: "$.users[*].user", want: `{"users":[{"user":"admin"},{"user":"app"}]}`},
+		{expr: "$.users[5].user", want: `{}`},
+		{expr: "$.user[*]", wantErr: true},
+	}
+
+	for _, c := range cases {
+		paths, err := ParseSelectPaths(c.expr)
+		if err != nil {
+			t.Fatalf("ParseSelectPaths(%q) failed: %v", c.expr, err)
+		}
+
+		selected, err := SelectJSON(snapshot, paths)
+		if (err != nil) != c.wantErr {
+			t.Fatalf("SelectJSON(%q) error = %v, wantErr %t", c.expr, err, c.wantErr)
+		}
+
+		if c.wantErr {
+			continue
+		}
+
+		got, _ := json.Marshal(selected)
+		if string(got) != c.want {
+			t.Errorf("SelectJSON(%q) = %s, want %s", c.expr, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"reflect"
+	"sort"
+)
+
+// selftestCheck 一项自检
+type selftestCheck struct {
+	Name  string
+	Check func() error
+}
+
+// selftest 不连接 MongoDB，使用构造的快照检查所有输出格式的序列化结果是否稳定、能否正确地往返转换，
+// 以及排序逻辑是否与输入顺序无关，用于在 CI 中发现新增字段引入的不确定输出，返回失败的检查数量
+func selftest(out io.Writer) int {
+	tmpl, err := parseTextTemplate("")
+	if err != nil {
+		panic(err)
+	}
+	textTemplate, jsonIndent, selectPaths = tmpl, "  ", nil
+
+	checks := []selftestCheck{{Name: "sort", Check: selftestSort}}
+	formats := make([]string, 0, len(supportedFormats))
+	for format := range supportedFormats {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+
+	for _, format := range formats {
+		format := format
+		checks = append(checks, selftestCheck{Name: "format " + format, Check: func() error { return selftestFormat(format) }})
+	}
+
+	failed := 0
+	for _, check := range checks {
+		if err := check.Check(); err != nil {
+			failed++
+			_, _ = fmt.Fprintf(out, "FAIL %s: %v\n", check.Name, err)
+			continue
+		}
+
+		_, _ = fmt.Fprintf(out, "ok   %s\n", check.Name)
+	}
+
+	return failed
+}
+
+// selftestSnapshot 构造一个所有字段都不为空的快照
+func selftestSnapshot() *Snapshot {
+	enabled, disabled := true, false
+	ttl := int64(3600)
+
+	snapshot := &Snapshot{
+		Databases:     []string{"admin", "app"},
+		DatabaseSizes: []DatabaseSize{{Name: "admin", SizeOnDiskMB: 0.04, Empty: false}, {Name: "app", SizeOnDiskMB: 1200, Empty: false}},
+		Users: []User{{ID: "app.reader", DB: "app", User: "reader", Mechanisms: []string{"SCRAM-SHA-256"}, Roles: []Role{
+			{DB: "admin", Role: "clusterMonitor"}, {DB: "app", Role: "read"},
+		}}},
+		Roles: []CustomRole{{DB: "app", Role: "auditor", Roles: []Role{{DB: "app", Role: "read"}}}},
+		Members: []ReplSetMemberConfig{
+			{ID: 0, Host: "db1:27017", BuildIndexes: true, Priority: 1, Votes: 1, Tags: map[string]string{"dc": "east", "rack": "r1", "az": "a"}},
+			{ID: 1, Host: "db2:27017", BuildIndexes: true, Priority: 0, Votes: 1},
+		},
+		WriteConcernModes: writeConcernModes(map[string]map[string]int{"multiDC": {"dc": 2, "rack": 1}}),
+		ReplSettings:      []ReplSetting{{Key: "chainingAllowed", Value: "true"}, {Key: "electionTimeoutMillis", Value: "10000"}},
+		ReplStats: []ReplMemberStat{
+			{ID: 0, Name: "db1:27017", State: "PRIMARY", Health: 1},
+			{ID: 1, Name: "db2:27017", State: "RECOVERING", Health: 1, SyncSourceHost: "db1:27017"},
+		},
+		Elections:       &ElectionMetrics{Called: 3, Won: 2, NumCatchUps: 1, AverageCatchUpOps: 0.5},
+		DBStats:         []DBStats{{DB: "app", Collections: 3, DataSizeMB: 12, Indexes: 5}},
+		CollCounts:      []DBCollCount{{DB: "admin", Collections: 2}, {DB: "app", Collections: 3}},
+		Indexes:         []Index{{DB: "app", Coll: "orders", Name: "_id_", Key: `{"_id":1}`}, {DB: "app", Coll: "orders", Name: "ttl", Key: `{"at":1}`, TTL: &ttl}, {DB: "app", Coll: "orders", Name: "status_1", Key: `{"status":1}`, Hidden: true, Partial: `{"status":{"$exists":true}}`}},
+		IndexSizes:      []IndexSize{{DB: "app", Coll: "orders", Name: "_id_", SizeMB: 0.5}},
+		DupIndexes:      []DupIndex{{DB: "app", Coll: "orders", Names: []string{"a_1", "a_1_dup"}}},
+		TLSCerts:        []TLSCert{{Host: "db1:27017", Expires: "2027-03-01", DaysLeft: 140}},
+		Hosts:           []HostInfo{{Host: "db1:27017", NumCores: 8, MemSizeMB: 16384, CPUArch: "x86_64", OSType: "Linux", OSName: "Ubuntu", OSVersion: "20.04"}},
+		Mongos:          []Mongos{{Host: "router1:27017", MongoVersion: "4.4.2"}},
+		Chunks:          []ChunkCount{{NS: "app.orders", Shard: "rs0", Count: 12}},
+		Migrations:      &Migrations{Window: "24h", Succeeded: 3},
+		Zones:           []Zone{{NS: "app.orders", Zone: "EU", Min: `{"region":"eu"}`, Max: `{"region":"ev"}`}},
+		Hello:           &Hello{Primary: "db1:27017", Me: "db1:27017", SetName: "rs0", MinWireVersion: 0, MaxWireVersion: 9},
+		TopologyVersion: &TopologyVersion{ProcessID: "5fb2a1c0e4b0a1a2b3c4d5e6", Counter: 6},
+		Storage:         StorageSettings{Engine: "wiredTiger", JournalEnabled: &enabled, Persistent: true},
+		Checkpoint:      &CheckpointSettings{IntervalSecs: "60", JournalCommitIntervalMs: "100"},
+		CurrentOp:       &CurrentOpSummary{Active: 3, LongestSecs: 12},
+		Transactions:    &TransactionSettings{LifetimeLimitSecs: "60", MaxLockRequestTimeoutMs: "5"},
+		OplogSize:       &OplogSize{MaxMB: 1024},
+		Scripting:       ScriptingSettings{JavascriptEnabled: &disabled},
+		Parameters:      []Parameter{{Name: "syncdelay", Value: "60"}},
+		FreeMonitoring:  &FreeMonitoring{State: "disabled"},
+		AuthProviders:   []AuthProviderSetting{{Provider: "internal", Key: "authorization", Value: "enabled"}},
+		CmdLine:         []CmdLineOption{{Key: "net.bindIp", Value: "0.0.0.0"}, {Key: "net.port", Value: "27017"}},
+		Commands:        []CommandAvailability{{Name: "eval", Available: false}, {Name: "getLog", Available: true}},
+		ClusterParams:   []ClusterParam{{Name: "changeStreamOptions", Value: `{"preAndPostImages":{"expireAfterSeconds":"off"}}`}},
+		ChangeStream:    &ChangeStreamOptions{PreImageRetention: "86400"},
+		Aggregations:    []AggregationLine{{Label: "orders_by_status", Doc: `{"_id":"paid","count":42}`}},
+		Custom:          []CustomLine{{Collector: "balancer", Line: "BALANCER: mode=full"}},
+		Errors:          []CollectorError{{Collector: "host_info", Error: "not authorized"}},
+	}
+
+	snapshot.Summary = snapshot.Summarize()
+	snapshot.Unhealthy = snapshot.UnhealthyMembers()
+	snapshot.NoElect = snapshot.NoElectMembers()
+
+	return snapshot
+}
+
+// selftestFormat 检查同一个快照多次输出的结果一致，并且 JSON 格式的输出解析之后再次输出的结果不变
+func selftestFormat(format string) error {
+	render := func(snapshot *Snapshot) (string, error) {
+		buffer := bytes.NewBuffer(nil)
+		if err := renderSnapshot(buffer, snapshot, format); err != nil {
+			return "", err
+		}
+
+		// ndjson 中的采集时间每次都不同，不参与比较
+		if format == "ndjson" {
+			var record map[string]interface{}
+			if err := json.Unmarshal(buffer.Bytes(), &record); err != nil {
+				return "", err
+			}
+			delete(record, "time")
+
+			data, err := json.Marshal(record)
+			return string(data), err
+		}
+
+		return buffer.String(), nil
+	}
+
+	first, err := render(selftestSnapshot())
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < 10; i++ {
+		again, err := render(selftestSnapshot())
+		if err != nil {
+			return err
+		}
+
+		if again != first {
+			return fmt.Errorf("output is not deterministic")
+		}
+	}
+
+	// 只有 JSON 格式的输出可以解析回快照
+	if format != "json" && format != "ndjson" {
+		return nil
+	}
+
+	var decoded Snapshot
+	if err := json.Unmarshal([]byte(first), &decoded); err != nil {
+		return fmt.Errorf("decode output failed: %w", err)
+	}
+
+	roundTrip, err := render(&decoded)
+	if err != nil {
+		return err
+	}
+
+	if roundTrip != first {
+		return fmt.Errorf("output changed after round trip")
+	}
+
+	return nil
+}
+
+// selftestSort 检查需要排序的数据在输入顺序不同时得到相同的结果
+func selftestSort() error {
+	roles := []Role{{DB: "app", Role: "read"}, {DB: "admin", Role: "root"}, {DB: "app", Role: "dbAdmin"}, {DB: "admin", Role: "backup"}}
+	modes := map[string]map[string]int{"a": {"dc": 2}, "b": {"rack": 3, "dc": 1}, "c": {"az": 1}}
+
+	expectedRoles := append([]Role{}, roles...)
+	sortRoles(expectedRoles)
+	expectedModes := writeConcernModes(modes)
+
+	for i := 0; i < 20; i++ {
+		shuffled := append([]Role{}, roles...)
+		rand.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+		sortRoles(shuffled)
+		if !reflect.DeepEqual(shuffled, expectedRoles) {
+			return fmt.Errorf("sortRoles depends on input order")
+		}
+
+		if !reflect.DeepEqual(writeConcernModes(modes), expectedModes) {
+			return fmt.Errorf("writeConcernModes depends on map iteration order")
+		}
+	}
+
+	return nil
+}
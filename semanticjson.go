@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SemanticJSON 按照结构对比 JSON 文档，对象字段的顺序不影响对比结果，IgnoreArrayOrder 为 true 时数组元素的顺序也不影响对比结果
+type SemanticJSON struct {
+	IgnoreArrayOrder bool
+}
+
+// parse 解析 JSON 文档，s 不是合法的 JSON 时返回 false
+func (sj SemanticJSON) parse(s string) (interface{}, bool) {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(s), &doc); err != nil {
+		return nil, false
+	}
+
+	return doc, true
+}
+
+// canonical 返回文档的规范形式，对象字段按照名称排序，忽略数组顺序时数组元素按照规范形式排序
+func (sj SemanticJSON) canonical(doc interface{}) string {
+	switch val := doc.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		items := make([]string, 0, len(keys))
+		for _, k := range keys {
+			key, _ := json.Marshal(k)
+			items = append(items, string(key)+":"+sj.canonical(val[k]))
+		}
+
+		return "{" + strings.Join(items, ",") + "}"
+	case []interface{}:
+		items := make([]string, 0, len(val))
+		for _, item := range val {
+			items = append(items, sj.canonical(item))
+		}
+
+		if sj.IgnoreArrayOrder {
+			sort.Strings(items)
+		}
+
+		return "[" + strings.Join(items, ",") + "]"
+	default:
+		data, _ := json.Marshal(val)
+		return string(data)
+	}
+}
+
+// Normalize 将 JSON 文档转换为规范形式，s 不是合法的 JSON 时原样返回
+func (sj SemanticJSON) Normalize(s string) string {
+	doc, ok := sj.parse(s)
+	if !ok {
+		return s
+	}
+
+	return sj.canonical(doc)
+}
+
+// Diff 按照结构对比两个 JSON 文档，返回基于路径的变更报告，每一行以 +（新增）、-（删除）、~（修改）开头，
+// 任意一个文档不是合法的 JSON 时返回 false
+//
+//	--- mongodb.20201010101010.stat
+//	+++ mongodb.new
+//	+ users[2].roles added "admin"
+//	~ members[0].priority changed 1 -> 2
+func (sj SemanticJSON) Diff(s1name, s1, s2name, s2 string) (string, bool) {
+	doc1, ok1 := sj.parse(s1)
+	doc2, ok2 := sj.parse(s2)
+	if !ok1 || !ok2 {
+		return "", false
+	}
+
+	changes := make([]string, 0)
+	sj.compare("", doc1, doc2, &changes)
+	if len(changes) == 0 {
+		return "", true
+	}
+
+	return fmt.Sprintf("--- %s\n+++ %s\n%s\n", s1name, s2name, strings.Join(changes, "\n")), true
+}
+
+func (sj SemanticJSON) compare(path string, v1, v2 interface{}, changes *[]string) {
+	switch val1 := v1.(type) {
+	case map[string]interface{}:
+		if val2, ok := v2.(map[string]interface{}); ok {
+			sj.compareObject(path, val1, val2, changes)
+			return
+		}
+	case []interface{}:
+		if val2, ok := v2.([]interface{}); ok {
+			if sj.IgnoreArrayOrder {
+				sj.compareUnorderedArray(path, val1, val2, changes)
+			} else {
+				sj.compareArray(path, val1, val2, changes)
+			}
+			return
+		}
+	}
+
+	if c1, c2 := sj.canonical(v1), sj.canonical(v2); c1 != c2 {
+		*changes = append(*changes, fmt.Sprintf("~ %s changed %s -> %s", sj.displayPath(path), c1, c2))
+	}
+}
+
+func (sj SemanticJSON) compareObject(path string, obj1, obj2 map[string]interface{}, changes *[]string) {
+	keys := make([]string, 0, len(obj1)+len(obj2))
+	for k := range obj1 {
+		keys = append(keys, k)
+	}
+	for k := range obj2 {
+		if _, ok := obj1[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		childPath := k
+		if path != "" {
+			childPath = path + "." + k
+		}
+
+		v1, ok1 := obj1[k]
+		v2, ok2 := obj2[k]
+		switch {
+		case !ok1:
+			*changes = append(*changes, fmt.Sprintf("+ %s added %s", childPath, sj.canonical(v2)))
+		case !ok2:
+			*changes = append(*changes, fmt.Sprintf("- %s removed %s", childPath, sj.canonical(v1)))
+		default:
+			sj.compare(childPath, v1, v2, changes)
+		}
+	}
+}
+
+// compareArray 按照下标对比数组元素
+func (sj SemanticJSON) compareArray(path string, arr1, arr2 []interface{}, changes *[]string) {
+	for i := 0; i < len(arr1) || i < len(arr2); i++ {
+		childPath := fmt.Sprintf("%s[%d]", path, i)
+		switch {
+		case i >= len(arr1):
+			*changes = append(*changes, fmt.Sprintf("+ %s added %s", childPath, sj.canonical(arr2[i])))
+		case i >= len(arr2):
+			*changes = append(*changes, fmt.Sprintf("- %s removed %s", childPath, sj.canonical(arr1[i])))
+		default:
+			sj.compare(childPath, arr1[i], arr2[i], changes)
+		}
+	}
+}
+
+// identityKeys 用于识别数组中同一个对象的字段，按照顺序使用第一个存在的字段
+var identityKeys = []string{"_id", "id", "name", "host"}
+
+// identity 返回对象的标识，不是对象或者没有标识字段时返回空字符串
+func (sj SemanticJSON) identity(item interface{}) string {
+	obj, ok := item.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+
+	for _, key := range identityKeys {
+		if v, ok := obj[key]; ok {
+			return key + "=" + sj.canonical(v)
+		}
+	}
+
+	return ""
+}
+
+// compareUnorderedArray 将数组视为多重集合对比，标识（identityKeys）相同的对象按照字段对比，其它元素报告为新增与删除
+func (sj SemanticJSON) compareUnorderedArray(path string, arr1, arr2 []interface{}, changes *[]string) {
+	counts := make(map[string]int)
+	for _, item := range arr1 {
+		counts[sj.canonical(item)]++
+	}
+
+	addedIndexes := make([]int, 0)
+	for i, item := range arr2 {
+		c := sj.canonical(item)
+		if counts[c] > 0 {
+			counts[c]--
+			continue
+		}
+
+		addedIndexes = append(addedIndexes, i)
+	}
+
+	removedItems := make(map[string]interface{})
+	removed := make([]string, 0)
+	for _, item := range arr1 {
+		c := sj.canonical(item)
+		if counts[c] > 0 {
+			counts[c]--
+			if id := sj.identity(item); id != "" {
+				if _, ok := removedItems[id]; !ok {
+					removedItems[id] = item
+					continue
+				}
+			}
+
+			removed = append(removed, c)
+		}
+	}
+
+	added := make([]string, 0)
+	for _, i := range addedIndexes {
+		if id := sj.identity(arr2[i]); id != "" {
+			if old, ok := removedItems[id]; ok {
+				delete(removedItems, id)
+				sj.compare(fmt.Sprintf("%s[%d]", path, i), old, arr2[i], changes)
+				continue
+			}
+		}
+
+		added = append(added, sj.canonical(arr2[i]))
+	}
+
+	for _, item := range removedItems {
+		removed = append(removed, sj.canonical(item))
+	}
+
+	sort.Strings(removed)
+	sort.Strings(added)
+	for _, c := range removed {
+		*changes = append(*changes, fmt.Sprintf("- %s removed %s", sj.displayPath(path), c))
+	}
+	for _, c := range added {
+		*changes = append(*changes, fmt.Sprintf("+ %s added %s", sj.displayPath(path), c))
+	}
+}
+
+func (sj SemanticJSON) displayPath(path string) string {
+	if path == "" {
+		return "$"
+	}
+
+	return path
+}
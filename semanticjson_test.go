@@ -0,0 +1,107 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestSemanticJSONNormalize(t *testing.T) {
+	cases := []struct {
+		name             string
+		ignoreArrayOrder bool
+		a, b             string
+		equal            bool
+	}{
+		{name: "field order", a: `{"a":1,"b":2}`, b: `{"b":2,"a":1}`, equal: true},
+		{name: "whitespace", a: "{\"a\": [1, 2]}", b: `{"a":[1,2]}`, equal: true},
+		{name: "nested field order", a: `{"a":{"x":1,"y":2}}`, b: `{"a":{"y":2,"x":1}}`, equal: true},
+		{name: "array order matters", a: `[1,2]`, b: `[2,1]`},
+		{name: "array order ignored", ignoreArrayOrder: true, a: `[1,2]`, b: `[2,1]`, equal: true},
+		{name: "array of objects order ignored", ignoreArrayOrder: true, a: `[{"id":1},{"id":2}]`, b: `[{"id":2},{"id":1}]`, equal: true},
+		{name: "duplicates are kept", ignoreArrayOrder: true, a: `[1,1,2]`, b: `[1,2,2]`},
+		{name: "value change", a: `{"a":1}`, b: `{"a":2}`},
+		{name: "number and string differ", a: `{"a":1}`, b: `{"a":"1"}`},
+		{name: "invalid json is kept as is", a: `not json`, b: `not json`, equal: true},
+		{name: "invalid json is not normalized", a: `{"a": 1`, b: `{"a":1`},
+	}
+
+	for _, c := range cases {
+		sj := SemanticJSON{IgnoreArrayOrder: c.ignoreArrayOrder}
+		if equal := sj.Normalize(c.a) == sj.Normalize(c.b); equal != c.equal {
+			t.Errorf("%s: Normalize(%q) == Normalize(%q) is %t, want %t", c.name, c.a, c.b, equal, c.equal)
+		}
+	}
+}
+
+func TestSemanticJSONDiff(t *testing.T) {
+	cases := []struct {
+		name             string
+		ignoreArrayOrder bool
+		a, b             string
+		want             string
+		invalid          bool
+	}{
+		{
+			name: "no change",
+			a:    `{"a":1,"b":[1,2]}`,
+			b:    `{"b":[1,2],"a":1}`,
+			want: "",
+		},
+		{
+			name: "field changed, added and removed",
+			a:    `{"a":1,"b":2}`,
+			b:    `{"a":3,"c":4}`,
+			want: "--- old\n+++ new\n~ a changed 1 -> 3\n- b removed 2\n+ c added 4\n",
+		},
+		{
+			name: "array element by index",
+			a:    `{"members":[{"host":"a","priority":1}]}`,
+			b:    `{"members":[{"host":"a","priority":2},{"host":"b","priority":1}]}`,
+			want: "--- old\n+++ new\n~ members[0].priority changed 1 -> 2\n+ members[1] added {\"host\":\"b\",\"priority\":1}\n",
+		},
+		{
+			name:             "reordered array ignored",
+			ignoreArrayOrder: true,
+			a:                `{"roles":["read","write"]}`,
+			b:                `{"roles":["write","read"]}`,
+			want:             "",
+		},
+		{
+			name:             "unordered array matched by identity",
+			ignoreArrayOrder: true,
+			a:                `{"members":[{"host":"a","priority":1},{"host":"b","priority":1}]}`,
+			b:                `{"members":[{"host":"b","priority":1},{"host":"a","priority":2}]}`,
+			want:             "--- old\n+++ new\n~ members[1].priority changed 1 -> 2\n",
+		},
+		{
+			name:             "unordered array added and removed",
+			ignoreArrayOrder: true,
+			a:                `["a","b"]`,
+			b:                `["b","c"]`,
+			want:             "--- old\n+++ new\n- $ removed \"a\"\n+ $ added \"c\"\n",
+		},
+		{
+			name: "type change",
+			a:    `{"a":{"x":1}}`,
+			b:    `{"a":[1]}`,
+			want: "--- old\n+++ new\n~ a changed {\"x\":1} -> [1]\n",
+		},
+		{
+			name:    "invalid json",
+			a:       `{"a":1}`,
+			b:       `A: 1`,
+			invalid: true,
+		},
+	}
+
+	for _, c := range cases {
+		sj := SemanticJSON{IgnoreArrayOrder: c.ignoreArrayOrder}
+		got, ok := sj.Diff("old", c.a, "new", c.b)
+		if ok == c.invalid {
+			t.Fatalf("%s: Diff ok = %t, want %t", c.name, ok, !c.invalid)
+		}
+
+		if got != c.want {
+			t.Errorf("%s: Diff() = %q, want %q", c.name, got, c.want)
+		}
+	}
+}
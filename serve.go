@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RunState 最近一次采集的结果
+type RunState struct {
+	Snapshot string    `json:"-"`
+	Diff     string    `json:"-"`
+	Changed  bool      `json:"changed"`
+	LastRun  time.Time `json:"last_run"`
+	Error    string    `json:"error,omitempty"`
+	// Hash 快照内容的 sha256，只在指定了 -snapshot-hash 时输出
+	Hash string `json:"hash,omitempty"`
+}
+
+// Server 以 HTTP 的方式对外提供最近一次采集的快照与差异
+type Server struct {
+	differ   *Differ
+	interval time.Duration
+	tracker  *RecoveryTracker
+
+	lock  sync.RWMutex
+	state RunState
+}
+
+// NewServer create a new Server
+func NewServer(differ *Differ, interval time.Duration) *Server {
+	return &Server{differ: differ, interval: interval, tracker: &RecoveryTracker{}}
+}
+
+func serve(addr string, interval time.Duration, differ *Differ) error {
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	srv := NewServer(differ, interval)
+	httpServer := &http.Server{Addr: addr, Handler: srv.Handler()}
+
+	go srv.Run(ctx)
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		<-ctx.Done()
+
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer shutdownCancel()
+
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			log.Printf("shutdown http server failed: %v", err)
+		}
+	}()
+
+	log.Printf("http server listening on %s", addr)
+	if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+
+	<-stopped
+	return nil
+}
+
+// Run 立即执行一次采集，之后按照 interval 周期性执行，直到 ctx 结束
+func (s *Server) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.collect()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Server) collect() {
+	state := RunState{LastRun: time.Now()}
+
+	snapshot, latest, err := collectAndDiff(s.differ)
+	if err != nil {
+		log.Printf("collect failed: %v", err)
+		state.Error = err.Error()
+	}
+
+	if err == nil || isSoftError(err) {
+		state.Snapshot = display(snapshot)
+		state.Diff = display(latest.String())
+		state.Changed = latest.Changed()
+		if snapshotHash {
+			state.Hash = latest.Hash()
+		}
+
+		if state.Changed {
+			if err := latest.Save(); err != nil {
+				log.Printf("save snapshot failed: %v", err)
+			}
+
+			notifyChange(s.tracker, latest)
+			runPostHook(true, display(latest.String()))
+
+			cleanVersions(latest)
+		}
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// 采集失败时保留上一次成功的快照，方便排查
+	if err != nil && !isSoftError(err) {
+		state.Snapshot = s.state.Snapshot
+	}
+	s.state = state
+}
+
+// State 返回最近一次采集的结果
+func (s *Server) State() RunState {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.state
+}
+
+// Handler 返回 HTTP 路由
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/snapshot", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = io.WriteString(w, s.State().Snapshot)
+	})
+	mux.HandleFunc("/diff", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = io.WriteString(w, s.State().Diff)
+	})
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(s.State())
+	})
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		state := s.State()
+		if state.LastRun.IsZero() || state.Error != "" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = io.WriteString(w, "unhealthy")
+			return
+		}
+
+		_, _ = io.WriteString(w, "ok")
+	})
+
+	return mux
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"strings"
+)
+
+// sideBySide 将 unified diff 渲染为左右两列，左侧为变化前、右侧为变化后，变化的行左右对齐，过长的行在列内折行
+//
+// 两列之间的标记：| 表示修改，< 表示只在左侧存在（删除），> 表示只在右侧存在（新增），空白表示没有变化
+func sideBySide(unified string, width int) string {
+	if unified == "" {
+		return ""
+	}
+
+	col := (width - 3) / 2
+	if col < 10 {
+		col = 10
+	}
+
+	var sb strings.Builder
+	var removed, added []string
+	var original string
+	flush := func() {
+		for i := 0; i < len(removed) || i < len(added); i++ {
+			left, right, marker := "", "", "|"
+			if i < len(removed) {
+				left = removed[i]
+			} else {
+				marker = ">"
+			}
+
+			if i < len(added) {
+				right = added[i]
+			} else {
+				marker = "<"
+			}
+
+			writeSideBySideRow(&sb, left, right, marker, col)
+		}
+
+		removed, added = nil, nil
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(unified, "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "--- "):
+			original = line[4:]
+		case strings.HasPrefix(line, "+++ "):
+			writeSideBySideRow(&sb, original, line[4:], " ", col)
+			sb.WriteString(strings.Repeat("=", col*2+3) + "\n")
+		case strings.HasPrefix(line, "@@"):
+			flush()
+			sb.WriteString(line + "\n")
+		case strings.HasPrefix(line, "-"):
+			removed = append(removed, line[1:])
+		case strings.HasPrefix(line, "+"):
+			added = append(added, line[1:])
+		default:
+			flush()
+			if line != "" {
+				line = line[1:]
+			}
+
+			writeSideBySideRow(&sb, line, line, " ", col)
+		}
+	}
+	flush()
+
+	return sb.String()
+}
+
+// writeSideBySideRow 输出一行，左右两侧的内容超过列宽时折行，折行后的每一行使用相同的标记
+func writeSideBySideRow(sb *strings.Builder, left, right, marker string, col int) {
+	lefts, rights := wrapRunes(left, col), wrapRunes(right, col)
+	for i := 0; i < len(lefts) || i < len(rights); i++ {
+		l, r := "", ""
+		if i < len(lefts) {
+			l = lefts[i]
+		}
+		if i < len(rights) {
+			r = rights[i]
+		}
+
+		row := l + strings.Repeat(" ", col-len([]rune(l))) + " " + marker + " " + r
+		sb.WriteString(strings.TrimRight(row, " ") + "\n")
+	}
+}
+
+// wrapRunes 将 s 按照 width 个字符拆分为多行，s 为空时返回一个空行
+func wrapRunes(s string, width int) []string {
+	runes := []rune(s)
+	if len(runes) == 0 {
+		return []string{""}
+	}
+
+	lines := make([]string, 0, len(runes)/width+1)
+	for len(runes) > width {
+		lines = append(lines, string(runes[:width]))
+		runes = runes[width:]
+	}
+
+	return append(lines, string(runes))
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TLSCert 服务端提供的 TLS 证书，DaysLeft 保留两位有效数字，避免每天都产生差异
+type TLSCert struct {
+	Host     string  `json:"host"`
+	Expires  string  `json:"expires"`
+	DaysLeft float64 `json:"days_left"`
+
+	notAfter time.Time
+}
+
+// CertExpiryError 存在即将过期的 TLS 证书（-tls-cert-warn-days）
+type CertExpiryError struct {
+	Certs []TLSCert
+}
+
+func (e CertExpiryError) Error() string {
+	hosts := make([]string, 0, len(e.Certs))
+	for _, cert := range e.Certs {
+		hosts = append(hosts, fmt.Sprintf("%s (%s)", cert.Host, cert.Expires))
+	}
+
+	return fmt.Sprintf("tls certificates expiring within %d days: %s", tlsCertWarnDays, strings.Join(hosts, ", "))
+}
+
+// tlsCerts 连接每一个成员（非副本集时为 URI 中的主机）读取服务端证书，未启用 TLS 时返回 nil，无法读取证书的成员只记录日志并跳过
+//
+// 只读取证书而不校验，已经过期或者不受信任的证书同样可以输出
+func tlsCerts(ctx context.Context, mongoURI string, members []ReplSetMemberConfig) ([]TLSCert, error) {
+	clientOption, err := newClientOptions(mongoURI)
+	if err != nil {
+		return nil, err
+	}
+
+	if clientOption.TLSConfig == nil {
+		return nil, nil
+	}
+
+	hosts := clientOption.Hosts
+	if len(members) > 0 {
+		hosts = make([]string, 0, len(members))
+		for _, member := range members {
+			hosts = append(hosts, member.Host)
+		}
+	}
+
+	certs := make([]TLSCert, 0, len(hosts))
+	for _, host := range hosts {
+		cert, err := tlsCert(ctx, clientOption.TLSConfig, host)
+		if err != nil {
+			log.Printf("read tls certificate of %s failed: %v", host, err)
+			continue
+		}
+
+		certs = append(certs, cert)
+	}
+
+	sort.Slice(certs, func(i, j int) bool { return certs[i].Host < certs[j].Host })
+	return certs, nil
+}
+
+func tlsCert(ctx context.Context, tlsConfig *tls.Config, host string) (TLSCert, error) {
+	conf := tlsConfig.Clone()
+	conf.InsecureSkipVerify = true
+	if conf.ServerName == "" {
+		if name, _, err := net.SplitHostPort(host); err == nil {
+			conf.ServerName = name
+		} else {
+			conf.ServerName = host
+		}
+	}
+
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	if deadline, ok := ctx.Deadline(); ok {
+		dialer.Deadline = deadline
+	}
+
+	conn, err := tls.DialWithDialer(dialer, "tcp", host, conf)
+	if err != nil {
+		return TLSCert{}, err
+	}
+	defer conn.Close()
+
+	peerCerts := conn.ConnectionState().PeerCertificates
+	if len(peerCerts) == 0 {
+		return TLSCert{}, fmt.Errorf("no certificate presented")
+	}
+
+	notAfter := peerCerts[0].NotAfter
+	return TLSCert{
+		Host:     host,
+		Expires:  notAfter.UTC().Format("2006-01-02"),
+		DaysLeft: roundSignificant(math.Floor(time.Until(notAfter).Hours() / 24)),
+		notAfter: notAfter,
+	}, nil
+}
+
+// ExpiringTLSCerts 返回 warnDays 天内过期的证书
+func (s *Snapshot) ExpiringTLSCerts(warnDays uint) []TLSCert {
+	expiring := make([]TLSCert, 0)
+	for _, cert := range s.TLSCerts {
+		if time.Until(cert.notAfter) < time.Duration(warnDays)*24*time.Hour {
+			expiring = append(expiring, cert)
+		}
+	}
+
+	return expiring
+}
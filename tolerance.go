@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// NumericTolerance 数值变化的容忍范围，Percent 为 true 时 Value 为百分比，否则为绝对值，
+// 只有 Keys 中的字段（数据量、数量等）才会应用容忍范围，优先级、投票数等配置的任何变化都需要输出
+type NumericTolerance struct {
+	Value   float64
+	Percent bool
+	Keys    map[string]bool
+}
+
+// defaultNumericToleranceKeys 默认应用容忍范围的字段，只包含数据量与数量
+const defaultNumericToleranceKeys = "sizeMB,dataSizeMB,storageSizeMB,sizeOnDiskMB,count,collections,objects"
+
+// toleranceKey 规范化字段名，使文本格式、snake_case 与 camelCase 的 JSON 字段名可以匹配同一个字段
+func toleranceKey(key string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(key), "_", ""))
+}
+
+// parseNumericTolerance 解析 -numeric-tolerance 参数，如 0.5 表示绝对值，5% 表示百分比，
+// keys 为逗号分隔的应用容忍范围的字段名
+func parseNumericTolerance(expr string, keys string) (*NumericTolerance, error) {
+	if expr == "" {
+		return nil, nil
+	}
+
+	tolerance := &NumericTolerance{Keys: make(map[string]bool)}
+	for _, key := range strings.Split(keys, ",") {
+		if key = toleranceKey(key); key != "" {
+			tolerance.Keys[key] = true
+		}
+	}
+
+	if len(tolerance.Keys) == 0 {
+		return nil, fmt.Errorf("-numeric-tolerance-keys must not be empty")
+	}
+
+	if strings.HasSuffix(expr, "%") {
+		tolerance.Percent = true
+		expr = strings.TrimSuffix(expr, "%")
+	}
+
+	value, err := strconv.ParseFloat(expr, 64)
+	if err != nil || value < 0 {
+		return nil, fmt.Errorf("invalid -numeric-tolerance value %q: must be a non-negative number or percentage such as 5%%", expr)
+	}
+
+	tolerance.Value = value
+	return tolerance, nil
+}
+
+// Within 判断 a 与 b 的差异是否在容忍范围内
+func (t NumericTolerance) Within(a, b float64) bool {
+	diff := math.Abs(a - b)
+	if t.Percent {
+		return diff <= t.Value/100*math.Max(math.Abs(a), math.Abs(b))
+	}
+
+	return diff <= t.Value
+}
+
+// numericValueRegexp 匹配字段值为数字的部分，包括文本格式的 key=123 与 JSON 格式的 "key": 123，
+// 不匹配主机名、端口等值中包含数字的情况
+var numericValueRegexp = regexp.MustCompile(`(\w+)(=|":\s*)(-?\d+(?:\.\d+)?)(,|\s|$)`)
+
+// numericShape 将行中 Keys 包含的数字字段值替换为占位符，并返回这些数字，其它数字字段保持原样
+func (t NumericTolerance) numericShape(line string) (string, []float64) {
+	values := make([]float64, 0)
+	shape := numericValueRegexp.ReplaceAllStringFunc(line, func(s string) string {
+		m := numericValueRegexp.FindStringSubmatch(s)
+		if !t.Keys[toleranceKey(m[1])] {
+			return s
+		}
+
+		value, _ := strconv.ParseFloat(m[3], 64)
+		values = append(values, value)
+		return m[1] + m[2] + "#" + m[4]
+	})
+
+	return shape, values
+}
+
+// tolerate 对于 target 中发生变化的行，如果 original 中存在只有数字字段值不同、且差异都在容忍范围内的行，
+// 则使用 original 中的行替换，使这些行在对比时被视为没有变化
+func (t NumericTolerance) tolerate(original, target string) string {
+	originalLines := strings.Split(original, "\n")
+	exists := make(map[string]bool, len(originalLines))
+	shapes := make(map[string][]string)
+	for _, line := range originalLines {
+		exists[line] = true
+		if shape, values := t.numericShape(line); len(values) > 0 {
+			shapes[shape] = append(shapes[shape], line)
+		}
+	}
+
+	lines := strings.Split(target, "\n")
+	for i, line := range lines {
+		if exists[line] {
+			continue
+		}
+
+		shape, values := t.numericShape(line)
+		if len(values) == 0 {
+			continue
+		}
+
+		for _, candidate := range shapes[shape] {
+			_, candidateValues := t.numericShape(candidate)
+			if t.withinAll(candidateValues, values) {
+				lines[i] = candidate
+				break
+			}
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (t NumericTolerance) withinAll(a, b []float64) bool {
+	for i := range a {
+		if !t.Within(a[i], b[i]) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseNumericTolerance(t *testing.T) {
+	cases := []struct {
+		expr    string
+		keys    string
+		want    *NumericTolerance
+		wantErr bool
+	}{
+		{expr: "", keys: defaultNumericToleranceKeys, want: nil},
+		{expr: "0.5", keys: "sizeMB", want: &NumericTolerance{Value: 0.5, Keys: map[string]bool{"sizemb": true}}},
+		{expr: "5%", keys: "data_size_mb, count", want: &NumericTolerance{Value: 5, Percent: true, Keys: map[string]bool{"datasizemb": true, "count": true}}},
+		{expr: "-1", keys: "sizeMB", wantErr: true},
+		{expr: "abc", keys: "sizeMB", wantErr: true},
+		{expr: "1", keys: " , ", wantErr: true},
+	}
+
+	for _, c := range cases {
+		got, err := parseNumericTolerance(c.expr, c.keys)
+		if (err != nil) != c.wantErr {
+			t.Fatalf("parseNumericTolerance(%q, %q) error = %v, wantErr %t", c.expr, c.keys, err, c.wantErr)
+		}
+
+		if c.wantErr {
+			continue
+		}
+
+		if (got == nil) != (c.want == nil) {
+			t.Fatalf("parseNumericTolerance(%q, %q) = %v, want %v", c.expr, c.keys, got, c.want)
+		}
+
+		if got == nil {
+			continue
+		}
+
+		if got.Value != c.want.Value || got.Percent != c.want.Percent || len(got.Keys) != len(c.want.Keys) {
+			t.Fatalf("parseNumericTolerance(%q, %q) = %+v, want %+v", c.expr, c.keys, got, c.want)
+		}
+
+		for key := range c.want.Keys {
+			if !got.Keys[key] {
+				t.Fatalf("parseNumericTolerance(%q, %q) missing key %q", c.expr, c.keys, key)
+			}
+		}
+	}
+}
+
+func TestNumericToleranceWithin(t *testing.T) {
+	cases := []struct {
+		tolerance NumericTolerance
+		a, b      float64
+		want      bool
+	}{
+		{tolerance: NumericTolerance{Value: 1}, a: 10, b: 11, want: true},
+		{tolerance: NumericTolerance{Value: 1}, a: 10, b: 11.5, want: false},
+		{tolerance: NumericTolerance{Value: 5, Percent: true}, a: 100, b: 105, want: true},
+		{tolerance: NumericTolerance{Value: 5, Percent: true}, a: 100, b: 106, want: false},
+		{tolerance: NumericTolerance{Value: 5, Percent: true}, a: -100, b: -96, want: true},
+		{tolerance: NumericTolerance{Value: 0}, a: 1, b: 1, want: true},
+	}
+
+	for _, c := range cases {
+		if got := c.tolerance.Within(c.a, c.b); got != c.want {
+			t.Errorf("%+v.Within(%v, %v) = %t, want %t", c.tolerance, c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestNumericToleranceTolerate(t *testing.T) {
+	tolerance, err := parseNumericTolerance("1", defaultNumericToleranceKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name     string
+		original string
+		target   string
+		// tolerated 为 true 时变化在容忍范围内，tolerate 返回 original
+		tolerated bool
+	}{
+		{
+			name:      "size within tolerance",
+			original:  "DB: name=app, sizeMB=10.5, empty=false",
+			target:    "DB: name=app, sizeMB=11, empty=false",
+			tolerated: true,
+		},
+		{
+			name:     "size beyond tolerance",
+			original: "DB: name=app, sizeMB=10.5, empty=false",
+			target:   "DB: name=app, sizeMB=12, empty=false",
+		},
+		{
+			name:     "priority change is reported",
+			original: "SETTING: id=1, host=db1:27017, priority=1, vote=1",
+			target:   "SETTING: id=1, host=db1:27017, priority=2, vote=1",
+		},
+		{
+			name:     "votes change is reported",
+			original: "SETTING: id=1, host=db1:27017, priority=1, vote=1",
+			target:   "SETTING: id=1, host=db1:27017, priority=1, vote=0",
+		},
+		{
+			name:     "member id change is reported",
+			original: "SETTING: id=1, host=db1:27017, priority=1, vote=1",
+			target:   "SETTING: id=2, host=db1:27017, priority=1, vote=1",
+		},
+		{
+			name:     "other field changes along with size",
+			original: "DB: name=app, sizeMB=10.5, empty=false",
+			target:   "DB: name=app, sizeMB=11, empty=true",
+		},
+		{
+			name:      "snake case json",
+			original:  `    "data_size_mb": 100,`,
+			target:    `    "data_size_mb": 101,`,
+			tolerated: true,
+		},
+		{
+			name:      "camel case json",
+			original:  `    "dataSizeMb": 100,`,
+			target:    `    "dataSizeMb": 100.5,`,
+			tolerated: true,
+		},
+		{
+			name:     "json priority",
+			original: `    "priority": 1,`,
+			target:   `    "priority": 2,`,
+		},
+		{
+			name:     "port in host is not a number field",
+			original: "SETTING: host=db1:27017",
+			target:   "SETTING: host=db1:27018",
+		},
+	}
+
+	for _, c := range cases {
+		got := tolerance.tolerate(c.original, c.target)
+		if tolerated := got == c.original; tolerated != c.tolerated {
+			t.Errorf("%s: tolerate(%q, %q) = %q, want tolerated %t", c.name, c.original, c.target, got, c.tolerated)
+		}
+	}
+}
+
+func TestNumericToleranceCustomKeys(t *testing.T) {
+	tolerance, err := parseNumericTolerance("1", "priority")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := tolerance.tolerate("SETTING: priority=1", "SETTING: priority=2"); got != "SETTING: priority=1" {
+		t.Errorf("expect priority to be tolerated when listed in keys, got %q", got)
+	}
+
+	if got := tolerance.tolerate("DB: sizeMB=1", "DB: sizeMB=2"); got != "DB: sizeMB=2" {
+		t.Errorf("expect sizeMB not to be tolerated when not listed in keys, got %q", got)
+	}
+}
+
+func TestDiffLatestWithNumericTolerance(t *testing.T) {
+	tolerance, err := parseNumericTolerance("5%", defaultNumericToleranceKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	differ := newTestDiffer(t).WithNumericTolerance(tolerance)
+	original := "DB: name=app, sizeMB=100, collections=10\nSETTING: id=0, priority=1, vote=1\n"
+	if err := differ.DiffLatest("test", original).Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name    string
+		target  string
+		changed bool
+	}{
+		{name: "all fields within tolerance", target: "DB: name=app, sizeMB=104, collections=10\nSETTING: id=0, priority=1, vote=1\n"},
+		{name: "one of the fields beyond tolerance", target: "DB: name=app, sizeMB=104, collections=11\nSETTING: id=0, priority=1, vote=1\n", changed: true},
+		{name: "priority change", target: "DB: name=app, sizeMB=100, collections=10\nSETTING: id=0, priority=1.04, vote=1\n", changed: true},
+		{name: "new line", target: original + "DB: name=other, sizeMB=100, collections=10\n", changed: true},
+	}
+
+	for _, c := range cases {
+		if changed := differ.DiffLatest("test", c.target).Changed(); changed != c.changed {
+			t.Errorf("%s: Changed() = %t, want %t", c.name, changed, c.changed)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+)
+
+// truncateValue 将超过 max 个字符的值截断，并追加完整内容的哈希，截断部分发生变化时仍然可以发现差异
+func truncateValue(value string, max int) string {
+	runes := []rune(value)
+	if max <= 0 || len(runes) <= max {
+		return value
+	}
+
+	sum := sha256.Sum256([]byte(value))
+	return string(runes[:max]) + "...[sha256:" + hex.EncodeToString(sum[:])[:12] + "]"
+}
+
+// truncateSnapshotValues 截断快照中所有超过 max 个字符的字符串值（-max-value-length），对所有输出格式生效
+func truncateSnapshotValues(snapshot *Snapshot, max int) {
+	if max <= 0 {
+		return
+	}
+
+	truncateValues(reflect.ValueOf(snapshot).Elem(), max)
+}
+
+func truncateValues(v reflect.Value, max int) {
+	switch v.Kind() {
+	case reflect.String:
+		if v.CanSet() {
+			v.SetString(truncateValue(v.String(), max))
+		}
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			truncateValues(v.Elem(), max)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			truncateValues(v.Field(i), max)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			truncateValues(v.Index(i), max)
+		}
+	case reflect.Map:
+		if v.Type().Elem().Kind() != reflect.String {
+			return
+		}
+
+		for _, key := range v.MapKeys() {
+			v.SetMapIndex(key, reflect.ValueOf(truncateValue(v.MapIndex(key).String(), max)).Convert(v.Type().Elem()))
+		}
+	}
+}
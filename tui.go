@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// 终端控制序列
+const (
+	ansiClear   = "\x1b[H\x1b[2J"
+	ansiReverse = "\x1b[7m"
+	ansiRed     = "\x1b[31m"
+	ansiGreen   = "\x1b[32m"
+	ansiCyan    = "\x1b[36m"
+	ansiReset   = "\x1b[0m"
+)
+
+// 按键
+const (
+	keyUnknown = iota
+	keyUp
+	keyDown
+	keyPageUp
+	keyPageDown
+	keyEnter
+	keyMark
+	keyQuit
+)
+
+// tui 交互式浏览历史版本与差异，通过 stty 将终端切换为 raw 模式，使用 ANSI 控制序列绘制界面
+type tui struct {
+	differ   *Differ
+	name     string
+	versions []Version
+	in       *bufio.Reader
+	out      io.Writer
+	rows     int
+	cols     int
+
+	// cursor 版本列表中当前选中的版本
+	cursor int
+	// mark 标记的对比起始版本，为 -1 时与选中版本的上一个版本对比
+	mark int
+}
+
+// runTUI 进入交互式界面，方向键选择版本，空格标记对比起始版本，回车查看差异，q 退出
+func runTUI(differ *Differ, name string) error {
+	versions, err := differ.Versions(name)
+	if err != nil {
+		return err
+	}
+
+	if len(versions) == 0 {
+		return fmt.Errorf("no saved versions for %s", name)
+	}
+
+	state, err := stty("-g")
+	if err != nil {
+		return fmt.Errorf("-tui requires an interactive terminal: %w", err)
+	}
+
+	if _, err := stty("raw", "-echo"); err != nil {
+		return err
+	}
+	defer func() {
+		_, _ = stty(state)
+		_, _ = io.WriteString(os.Stdout, ansiClear)
+	}()
+
+	t := &tui{
+		differ:   differ,
+		name:     name,
+		versions: versions,
+		in:       bufio.NewReader(os.Stdin),
+		out:      os.Stdout,
+		rows:     24,
+		cols:     80,
+		cursor:   len(versions) - 1,
+		mark:     -1,
+	}
+
+	// 部分终端未设置窗口大小时返回 0 0，此时使用默认大小
+	if size, err := stty("size"); err == nil {
+		var rows, cols int
+		if _, err := fmt.Sscanf(size, "%d %d", &rows, &cols); err == nil && rows > 0 && cols > 0 {
+			t.rows, t.cols = rows, cols
+		}
+	}
+
+	return t.listView()
+}
+
+// stty 对当前终端执行 stty 命令，返回其输出
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// readKey 读取一次按键
+func (t *tui) readKey() (int, error) {
+	b, err := t.in.ReadByte()
+	if err != nil {
+		return keyUnknown, err
+	}
+
+	switch b {
+	case 'k':
+		return keyUp, nil
+	case 'j':
+		return keyDown, nil
+	case '\r', '\n':
+		return keyEnter, nil
+	case ' ':
+		return keyMark, nil
+	case 'q', 3:
+		return keyQuit, nil
+	case 0x1b:
+		if t.in.Buffered() == 0 {
+			return keyQuit, nil
+		}
+
+		seq := make([]byte, 0, 3)
+		for t.in.Buffered() > 0 && len(seq) < 3 {
+			c, _ := t.in.ReadByte()
+			seq = append(seq, c)
+		}
+
+		switch string(seq) {
+		case "[A", "OA":
+			return keyUp, nil
+		case "[B", "OB":
+			return keyDown, nil
+		case "[5~":
+			return keyPageUp, nil
+		case "[6~":
+			return keyPageDown, nil
+		}
+	}
+
+	return keyUnknown, nil
+}
+
+// draw 绘制一屏内容，lines 超出终端宽度的部分被截断
+func (t *tui) draw(header string, lines []string, footer string) {
+	var sb strings.Builder
+	sb.WriteString(ansiClear)
+	sb.WriteString(ansiReverse + t.truncate(header) + ansiReset + "\r\n")
+	for _, line := range lines {
+		sb.WriteString(line + "\r\n")
+	}
+	sb.WriteString(ansiCyan + t.truncate(footer) + ansiReset)
+
+	_, _ = io.WriteString(t.out, sb.String())
+}
+
+func (t *tui) truncate(line string) string {
+	runes := []rune(line)
+	if len(runes) > t.cols {
+		return string(runes[:t.cols])
+	}
+
+	return line
+}
+
+// pageSize 除去标题与提示行之后可以显示的行数
+func (t *tui) pageSize() int {
+	if t.rows > 3 {
+		return t.rows - 2
+	}
+
+	return 1
+}
+
+// listView 版本列表界面
+func (t *tui) listView() error {
+	offset := 0
+	for {
+		page := t.pageSize()
+		if t.cursor < offset {
+			offset = t.cursor
+		}
+		if t.cursor >= offset+page {
+			offset = t.cursor - page + 1
+		}
+
+		lines := make([]string, 0, page)
+		for i := offset; i < len(t.versions) && i < offset+page; i++ {
+			version := t.versions[i]
+			flag := " "
+			if i == t.mark {
+				flag = "*"
+			}
+
+			line := t.truncate(fmt.Sprintf("%s %s  %s  %s", flag, version.Timestamp, version.File, version.Message))
+			if i == t.cursor {
+				line = ansiReverse + line + ansiReset
+			}
+
+			lines = append(lines, line)
+		}
+
+		t.draw(
+			fmt.Sprintf("%s: %d versions", t.name, len(t.versions)),
+			lines,
+			"↑/↓ 选择  空格 标记对比起始版本  回车 查看差异  q 退出",
+		)
+
+		key, err := t.readKey()
+		if err != nil {
+			return err
+		}
+
+		switch key {
+		case keyUp:
+			if t.cursor > 0 {
+				t.cursor--
+			}
+		case keyDown:
+			if t.cursor < len(t.versions)-1 {
+				t.cursor++
+			}
+		case keyPageUp:
+			t.cursor = maxInt(t.cursor-page, 0)
+		case keyPageDown:
+			t.cursor = minInt(t.cursor+page, len(t.versions)-1)
+		case keyMark:
+			if t.mark == t.cursor {
+				t.mark = -1
+			} else {
+				t.mark = t.cursor
+			}
+		case keyEnter:
+			if err := t.diffView(); err != nil {
+				return err
+			}
+		case keyQuit:
+			return nil
+		}
+	}
+}
+
+// diffView 差异界面，对比标记的版本（未标记时为上一个版本）与选中的版本
+func (t *tui) diffView() error {
+	from := t.cursor - 1
+	if t.mark >= 0 {
+		from = t.mark
+	}
+	if from < 0 || from == t.cursor {
+		return nil
+	}
+
+	fromVersion, toVersion := t.versions[from], t.versions[t.cursor]
+	if from > t.cursor {
+		fromVersion, toVersion = toVersion, fromVersion
+	}
+
+	diffText, err := t.differ.DiffVersions(fromVersion, toVersion)
+	if err != nil {
+		return err
+	}
+
+	lines := strings.Split(strings.TrimRight(displayDiff(diffText), "\n"), "\n")
+	if diffText == "" {
+		lines = []string{"(no changes)"}
+	}
+
+	offset := 0
+	for {
+		page := t.pageSize()
+		end := minInt(offset+page, len(lines))
+
+		visible := make([]string, 0, page)
+		for _, line := range lines[offset:end] {
+			visible = append(visible, colorDiffLine(t.truncate(line)))
+		}
+
+		t.draw(
+			fmt.Sprintf("%s → %s  (%d-%d/%d)", fromVersion.File, toVersion.File, offset+1, end, len(lines)),
+			visible,
+			"↑/↓ 滚动  PgUp/PgDn 翻页  q 返回",
+		)
+
+		key, err := t.readKey()
+		if err != nil {
+			return err
+		}
+
+		maxOffset := maxInt(len(lines)-page, 0)
+		switch key {
+		case keyUp:
+			offset = maxInt(offset-1, 0)
+		case keyDown:
+			offset = minInt(offset+1, maxOffset)
+		case keyPageUp:
+			offset = maxInt(offset-page, 0)
+		case keyPageDown:
+			offset = minInt(offset+page, maxOffset)
+		case keyQuit, keyEnter:
+			return nil
+		}
+	}
+}
+
+// colorDiffLine 根据统一差异格式的行首字符着色
+func colorDiffLine(line string) string {
+	switch {
+	case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+		return line
+	case strings.HasPrefix(line, "+"):
+		return ansiGreen + line + ansiReset
+	case strings.HasPrefix(line, "-"):
+		return ansiRed + line + ansiReset
+	case strings.HasPrefix(line, "@@"):
+		return ansiCyan + line + ansiReset
+	}
+
+	return line
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+
+	return b
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+)
+
+// transactionsMinWireVersion 支持多文档事务的最低 wire 协议版本（4.0）
+const transactionsMinWireVersion = 7
+
+// TransactionSettings 多文档事务相关的服务端参数，变化会影响长事务的行为
+type TransactionSettings struct {
+	// LifetimeLimitSecs 事务的最长存活时间（transactionLifetimeLimitSeconds），单位秒
+	LifetimeLimitSecs string `json:"lifetime_limit_secs"`
+	// MaxLockRequestTimeoutMs 事务等待锁的最长时间（maxTransactionLockRequestTimeoutMillis），单位毫秒，未上报时为空
+	MaxLockRequestTimeoutMs string `json:"max_lock_request_timeout_ms"`
+}
+
+// supportsTransactions 判断当前连接的节点是否支持多文档事务，单机与 4.0 之前的版本不支持
+func (h Hello) supportsTransactions() bool {
+	if h.MaxWireVersion < transactionsMinWireVersion {
+		return false
+	}
+
+	return h.SetName != "" || h.IsMongos()
+}
+
+// Transactions 返回多文档事务相关的服务端参数，服务端没有上报（如 mongos）时返回 nil
+func (mm *MongoManager) Transactions(ctx context.Context) (*TransactionSettings, error) {
+	params, err := mm.Parameters(ctx, []string{"transactionLifetimeLimitSeconds", "maxTransactionLockRequestTimeoutMillis"})
+	if err != nil {
+		return nil, err
+	}
+
+	settings := &TransactionSettings{}
+	for _, param := range params {
+		switch param.Name {
+		case "transactionLifetimeLimitSeconds":
+			settings.LifetimeLimitSecs = param.Value
+		case "maxTransactionLockRequestTimeoutMillis":
+			settings.MaxLockRequestTimeoutMs = param.Value
+		}
+	}
+
+	if settings.LifetimeLimitSecs == "" {
+		return nil, nil
+	}
+
+	return settings, nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io"
+	"log"
+	"time"
+)
+
+// watch 按照 interval 周期性采集，只在状态发生变化时输出 diff
+//
+// MongoDB 的用户、角色等系统数据存储在系统集合中，无法通过 change stream 监听，
+// 因此这里采用轮询的方式实现。采集失败（如连接临时中断）时只记录日志，下个周期自动重试。
+func watch(interval time.Duration, differ *Differ, out io.Writer) error {
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	tracker := &RecoveryTracker{}
+	for {
+		_, latest, err := collectAndDiff(differ)
+		if err != nil {
+			log.Printf("collect failed, retry in %s: %v", interval, err)
+		}
+
+		if (err == nil || isSoftError(err)) && latest.Changed() {
+			if err := printAndSave(out, latest); err != nil {
+				log.Printf("save snapshot failed: %v", err)
+			}
+			flushOutput(out)
+
+			notifyChange(tracker, latest)
+			runPostHook(true, display(latest.String()))
+
+			cleanVersions(latest)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Zone 分片集群中的一个 zone 范围（config.tags），决定了数据在分片间的分布
+type Zone struct {
+	NS   string `json:"ns"`
+	Zone string `json:"zone"`
+	Min  string `json:"min"`
+	Max  string `json:"max"`
+}
+
+type zoneRange struct {
+	NS  string   `bson:"ns"`
+	Tag string   `bson:"tag"`
+	Min bson.Raw `bson:"min"`
+	Max bson.Raw `bson:"max"`
+}
+
+// Zones 返回 config.tags 中的 zone 范围，按照 ns、zone、min 排序，min、max 以扩展 JSON 格式输出
+func (mm *MongoManager) Zones(ctx context.Context) ([]Zone, error) {
+	cursor, err := mm.conn.Database("config").Collection("tags").Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+
+	var ranges []zoneRange
+	if err := cursor.All(ctx, &ranges); err != nil {
+		return nil, err
+	}
+
+	zones := make([]Zone, 0, len(ranges))
+	for _, r := range ranges {
+		min, err := bson.MarshalExtJSON(r.Min, false, false)
+		if err != nil {
+			return nil, err
+		}
+
+		max, err := bson.MarshalExtJSON(r.Max, false, false)
+		if err != nil {
+			return nil, err
+		}
+
+		zones = append(zones, Zone{NS: r.NS, Zone: r.Tag, Min: string(min), Max: string(max)})
+	}
+
+	sort.Slice(zones, func(i, j int) bool {
+		if zones[i].NS != zones[j].NS {
+			return zones[i].NS < zones[j].NS
+		}
+
+		if zones[i].Zone != zones[j].Zone {
+			return zones[i].Zone < zones[j].Zone
+		}
+
+		return zones[i].Min < zones[j].Min
+	})
+
+	return zones, nil
+}